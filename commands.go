@@ -0,0 +1,691 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Zeglius/yafti-go/config"
+	"github.com/Zeglius/yafti-go/executor"
+	"github.com/Zeglius/yafti-go/internal/consts"
+	"github.com/Zeglius/yafti-go/internal/instance"
+	"github.com/Zeglius/yafti-go/internal/notify"
+	"github.com/Zeglius/yafti-go/internal/wrapper"
+	"github.com/Zeglius/yafti-go/internal/xdg"
+	srv "github.com/Zeglius/yafti-go/server"
+	"github.com/Zeglius/yafti-go/tui"
+	"github.com/godbus/dbus/v5"
+	"github.com/labstack/gommon/log"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sys/unix"
+	"golang.org/x/term"
+)
+
+func cmdServe(args []string) int {
+	fs := newFlagSet("serve", "", "Start the web interface, and optionally open it with a wrapper command.")
+	common := addCommonFlags(fs)
+	host := fs.String("addr", envOr("YAFTI_ADDR", consts.HOST), "address to listen on (env: YAFTI_ADDR)")
+	port := fs.String("port", envOr("YAFTI_PORT", consts.PORT), "port to listen on, 0 picks a free one (env: YAFTI_PORT)")
+	socket := fs.String("socket", os.Getenv("YAFTI_SOCKET"), "also listen on a Unix socket at this path, \"auto\" uses $XDG_RUNTIME_DIR/yafti/yafti.sock (env: YAFTI_SOCKET)")
+	idleTimeout := fs.Duration("idle-timeout", envDuration("YAFTI_IDLE_TIMEOUT", consts.IDLE_TIMEOUT), "shut down after no page is open for this long, 0 disables it (env: YAFTI_IDLE_TIMEOUT, config: idle_timeout)")
+	force := fs.Bool("force", false, "show every screen and action, even if already done")
+	resume := fs.Bool("resume", false, "resume unfinished runs right away, instead of asking")
+	dryRun := fs.Bool("dry-run", false, "only preview what would be executed, never run anything")
+	manage := fs.Bool("manage", false, "show what is installed, and remove the actions turned off. Implies --force")
+	grace := fs.Duration("grace-period", envDuration("YAFTI_GRACE_PERIOD", executor.DefaultGracePeriod), "time running scripts get to exit when stopped by a signal (env: YAFTI_GRACE_PERIOD)")
+	// If set, the server will be started and the wrapper command will be executed
+	wrapperCmd := fs.String("wrapper", os.Getenv("YAFTI_EXEC_WRAPPER"), "command used to open the interface, %u is replaced by its URL (env: YAFTI_EXEC_WRAPPER)")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if code, ok := common.setup(); !ok {
+		return code
+	}
+
+	// Only one server per user, later launches just open the running one.
+	// Checked before the state, so a launch always reaches a running instance.
+	lock, err := instance.Acquire()
+	if errors.Is(err, instance.ErrRunning) {
+		return handOff(*wrapperCmd)
+	} else if err != nil {
+		log.Error(err)
+		return exitError
+	}
+	defer lock.Release()
+
+	// Instantiate server
+	ex := newExecutor(common.configPath, *grace)
+	if code, ok := openState(ex, *force || *manage); !ok {
+		return code
+	}
+	if ex.Journal != nil {
+		ex.Journal.ResumeExec = resumeCommand(common.configPath, *wrapperCmd)
+	}
+	server := srv.New(ex)
+	server.Addr = net.JoinHostPort(*host, *port)
+	server.SetLogLevel(common.lvl)
+	server.Version = version
+	server.DryRun = *dryRun
+	server.Manage = *manage
+	server.Notifier = newNotifier()
+	server.Reopen = func(url string) {
+		if err := openInterface(*wrapperCmd, url); err != nil {
+			log.Errorf("Failed to open the interface: %v", err)
+		}
+	}
+
+	// The flag and environment win over the config file
+	server.IdleTimeout = *idleTimeout
+	if !flagSet(fs, "idle-timeout") && os.Getenv("YAFTI_IDLE_TIMEOUT") == "" && config.ConfStatus.IdleTimeout != 0 {
+		server.IdleTimeout = config.ConfStatus.IdleTimeout
+	}
+
+	// Load static assets
+	server.StaticAssets = &static
+
+	if *socket == "auto" {
+		dir, err := xdg.RuntimeDir()
+		if err != nil {
+			log.Error(err)
+			return exitError
+		}
+		*socket = filepath.Join(dir, "yafti.sock")
+	}
+	server.SocketPath = *socket
+
+	if err := server.Listen(); err != nil {
+		// Unless a port was explicitly asked for, fall back to any free port
+		if !errors.Is(err, syscall.EADDRINUSE) || flagSet(fs, "port") || os.Getenv("YAFTI_PORT") != "" {
+			log.Error(err)
+			return exitError
+		}
+		log.Warnf("Port %s is in use, picking a free one", *port)
+		server.Addr = net.JoinHostPort(*host, "0")
+		if err := server.Listen(); err != nil {
+			log.Error(err)
+			return exitError
+		}
+	}
+
+	if err := lock.Publish(server.URL()); err != nil {
+		log.Warnf("Failed to publish instance URL: %v", err)
+	}
+
+	// Relaunched after a reboot, carry on with what is left
+	if *resume && !*dryRun {
+		for _, p := range ex.Pending() {
+			if _, err := server.Resume(p); err != nil {
+				log.Errorf("Failed to resume run %s: %v", p.RunID, err)
+			}
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Stop cleanly when the session ends or systemd stops us
+	sigCtx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var errg errgroup.Group
+	errg.Go(func() error {
+		// Terminate the wrapper once the server is gone
+		defer cancel()
+		if err := server.Start(); err != nil && err != http.ErrServerClosed {
+			return err
+		}
+		return nil
+	})
+	errg.Go(func() error {
+		<-sigCtx.Done()
+		if ctx.Err() == nil {
+			log.Info("Received signal, shutting down")
+			shutdown(ex, server)
+		}
+		return nil
+	})
+
+	// If a wrapper command is provided, we supervise it alongside the server.
+	// The server is already listening, so its URL is the definitive one.
+	if *wrapperCmd != "" {
+		sup := &wrapper.Supervisor{
+			Cmd:  strings.ReplaceAll(*wrapperCmd, "%u", server.URL()),
+			Busy: ex.Busy,
+			OnIdleExit: func() {
+				if err := server.Shutdown(context.Background()); err != nil {
+					log.Errorf("Shutdown error: %v", err)
+				}
+			},
+		}
+		errg.Go(func() error {
+			sup.Run(ctx)
+			return nil
+		})
+	}
+
+	if err := errg.Wait(); err != nil {
+		log.Error(err)
+		return exitError
+	}
+	return exitOK
+}
+
+// shutdown interrupts the runs in progress, giving their scripts the grace
+// period to exit, then stops the server.
+func shutdown(ex *executor.Executor, server *srv.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), ex.GracePeriod+5*time.Second)
+	defer cancel()
+
+	if err := ex.Shutdown(ctx); err != nil {
+		log.Errorf("Runs did not stop in time: %v", err)
+	}
+	if err := server.Shutdown(ctx); err != nil {
+		log.Errorf("Shutdown error: %v", err)
+	}
+}
+
+// handOff opens the interface of the already running instance with the
+// wrapper command, instead of starting a second server.
+func handOff(wrapper string) int {
+	// The other instance may still be starting up, give it a moment to listen
+	var info instance.Info
+	for range 50 {
+		var err error
+		if info, err = instance.Running(); err == nil && info.URL != "" {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if info.URL == "" {
+		log.Error("Another instance holds the lock but is not listening")
+		return exitError
+	}
+
+	client := http.Client{Timeout: 2 * time.Second}
+	resp, err := client.Get(info.URL + "/_/ping")
+	if err != nil {
+		log.Errorf("Another instance (pid %d) is not responding: %v", info.PID, err)
+		return exitError
+	}
+	resp.Body.Close()
+
+	log.Infof("yafti is already running at %s (pid %d)", info.URL, info.PID)
+	if wrapper == "" {
+		return exitOK
+	}
+
+	if err := openInterface(wrapper, info.URL); err != nil {
+		log.Error(err)
+		return exitError
+	}
+	return exitOK
+}
+
+// openInterface opens url with the wrapper command, or with the default
+// browser if there is none.
+func openInterface(wrapper, url string) error {
+	cmd := exec.Command("xdg-open", url)
+	if wrapper != "" {
+		cmd = exec.Command("sh", "-c", strings.ReplaceAll(wrapper, "%u", url))
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}
+
+// newNotifier returns a notifier on the session bus, or nil if there is
+// none to reach.
+func newNotifier() *notify.Notifier {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		log.Warnf("No desktop notifications, failed to connect to the session bus: %v", err)
+		return nil
+	}
+	n, err := notify.New(conn)
+	if err != nil {
+		log.Warnf("No desktop notifications: %v", err)
+		conn.Close()
+		return nil
+	}
+	n.DesktopEntry = "yafti"
+	return n
+}
+
+func cmdTUI(args []string) int {
+	fs := newFlagSet("tui", "", "Start the terminal interface.")
+	common := addCommonFlags(fs)
+	force := fs.Bool("force", false, "show every screen and action, even if already done")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if code, ok := common.setup(); !ok {
+		return code
+	}
+
+	ex := newExecutor(common.configPath, executor.DefaultGracePeriod)
+	if code, ok := openState(ex, *force); !ok {
+		return code
+	}
+	if ex.Journal != nil {
+		ex.Journal.ResumeExec = resumeCommand(common.configPath, "")
+	}
+
+	if err := tui.Run(config.ConfStatus, ex); err != nil {
+		fmt.Fprintf(os.Stderr, "yafti: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
+
+func cmdValidate(args []string) int {
+	fs := newFlagSet("validate", "", "Check the config file for errors.")
+	common := addCommonFlags(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if code, ok := common.setup(); !ok {
+		return code
+	}
+
+	fmt.Printf("%s: OK\n", common.configPath)
+	return exitOK
+}
+
+func cmdRun(args []string) int {
+	fs := newFlagSet("run", " [action-id...]", "Run the given actions, printing their output.")
+	common := addCommonFlags(fs)
+	defaults := fs.Bool("defaults", false, "run the actions enabled by default instead of the given ones")
+	dryRun := fs.Bool("dry-run", false, "print what would be executed instead of running it")
+	asJSON := fs.Bool("json", false, "with --dry-run, print the plan as JSON")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if code, ok := common.setup(); !ok {
+		return code
+	}
+
+	ids := fs.Args()
+	if *defaults {
+		for act := range config.ConfStatus.GetAllActions() {
+			if act.Default {
+				ids = append(ids, act.ID)
+			}
+		}
+	}
+	if len(ids) == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	for _, id := range ids {
+		if _, found := config.ConfStatus.GetActionsByIds([]string{id}); !found {
+			fmt.Fprintf(os.Stderr, "yafti: unknown action %q\n", id)
+			return exitUsage
+		}
+	}
+
+	actions, _ := config.ConfStatus.GetActionsByIds(ids)
+	ex := newExecutor(common.configPath, executor.DefaultGracePeriod)
+	if *dryRun {
+		return printPlan(ex.Plan(context.Background(), actions), *asJSON)
+	}
+	// Interactive scripts are attached to the terminal of yafti
+	if i := slices.IndexFunc(actions, func(act config.Action) bool { return act.Interactive }); i >= 0 && !term.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Fprintf(os.Stderr, "yafti: %s asks for input, run yafti from a terminal to answer it\n", actions[i].Title)
+		return exitError
+	}
+	// Recorded like the runs of the interfaces, and resumed by the web
+	// interface after a reboot
+	if code, ok := openState(ex, true); !ok {
+		return code
+	}
+	if ex.Journal != nil {
+		ex.Journal.ResumeExec = resumeCommand(common.configPath, "")
+	}
+	run, err := ex.Start(actions)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "yafti: %v\n", err)
+		return exitError
+	}
+
+	// Interrupt the scripts cleanly when stopped
+	sigCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		select {
+		case <-sigCtx.Done():
+			ex.Shutdown(context.Background())
+		case <-run.Done():
+		}
+	}()
+
+	stdin := bufio.NewReader(os.Stdin)
+	failed := false
+	last := -1 // Action the last printed line belongs to
+	for ev := range run.Events(context.Background()) {
+		// Parallel actions interleave their output, tell whose it is
+		switch ev.Kind {
+		case executor.ActionOutput, executor.ActionStatus, executor.ActionWarning, executor.ActionLink:
+			if ev.Index != last {
+				fmt.Printf("==> %s\n", run.Actions[ev.Index].Title)
+			}
+		}
+		if ev.Kind != executor.RunFinished {
+			last = ev.Index
+		}
+
+		switch ev.Kind {
+		case executor.ActionStarted:
+			fmt.Printf("==> %s\n", run.Actions[ev.Index].Title)
+		case executor.ActionOutput:
+			// Interactive scripts were shown in their terminal already
+			if !run.Actions[ev.Index].Interactive {
+				fmt.Println(ev.Text)
+			}
+		case executor.TerminalOpened:
+			if t, ok := run.Terminal(ev.Terminal); ok {
+				attachTerminal(t)
+				last = -1
+			}
+		case executor.ActionStatus:
+			fmt.Printf("--> %s\n", ev.Text)
+		case executor.ActionWarning:
+			fmt.Printf("Warning: %s\n", ev.Text)
+		case executor.ActionLink:
+			fmt.Printf("%s: %s\n", ev.Text, ev.URL)
+		case executor.ActionFinished:
+			if ev.Status == executor.StatusNotRun {
+				failed = true
+				fmt.Printf("==> %s was not run: %s\n", run.Actions[ev.Index].Title, ev.Text)
+			} else if ev.Failed() {
+				failed = true
+				fmt.Printf("==> %s failed (exit code %d) %s\n", run.Actions[ev.Index].Title, ev.ExitCode, ev.Text)
+			} else if ev.Status == executor.StatusSkipped {
+				fmt.Printf("==> %s is already applied, skipped\n", run.Actions[ev.Index].Title)
+			}
+		case executor.UndoStarted:
+			fmt.Printf("==> Reverting %s\n", run.Actions[ev.Index].Title)
+		case executor.UndoFinished:
+			if ev.Status == executor.StatusReverted {
+				fmt.Printf("==> %s reverted\n", run.Actions[ev.Index].Title)
+			} else {
+				fmt.Printf("==> Reverting %s failed (exit code %d) %s\n", run.Actions[ev.Index].Title, ev.ExitCode, ev.Text)
+			}
+		case executor.DecisionRequired:
+			if err := run.Decide(ev.Index, askDecision(stdin)); err != nil {
+				fmt.Fprintf(os.Stderr, "yafti: %v\n", err)
+			}
+		case executor.RebootRequired:
+			if ev.Index < len(run.Actions)-1 {
+				failed = true
+				if ex.Journal != nil {
+					fmt.Printf("==> %s requires a reboot, the remaining actions run on the next login\n", run.Actions[ev.Index].Title)
+				} else {
+					fmt.Printf("==> %s requires a reboot, the remaining actions were skipped\n", run.Actions[ev.Index].Title)
+				}
+			} else {
+				fmt.Printf("==> %s requires a reboot\n", run.Actions[ev.Index].Title)
+			}
+		}
+	}
+
+	if failed {
+		return exitRunFailed
+	}
+	return exitOK
+}
+
+// askDecision asks on the terminal what to do about a failed action. The
+// run is aborted if there is no terminal to ask on.
+func askDecision(stdin *bufio.Reader) executor.Decision {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Println("==> Not running the remaining actions, there is no terminal to ask what to do")
+		return executor.DecisionAbort
+	}
+	for {
+		fmt.Print("==> [r]etry, [s]kip or [a]bort the rest? ")
+		line, err := stdin.ReadString('\n')
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "r", "retry":
+			return executor.DecisionRetry
+		case "s", "skip":
+			return executor.DecisionSkip
+		case "a", "abort":
+			return executor.DecisionAbort
+		}
+		if err != nil {
+			return executor.DecisionAbort
+		}
+	}
+}
+
+// attachTerminal connects the terminal of an interactive action to the
+// one of yafti, until the script exits.
+func attachTerminal(t *executor.Terminal) {
+	fd := int(os.Stdin.Fd())
+	oldState, err := term.MakeRaw(fd)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "yafti: %v\n", err)
+		return
+	}
+	defer term.Restore(fd, oldState)
+
+	resize := func() {
+		if width, height, err := term.GetSize(fd); err == nil {
+			t.Resize(width, height)
+		}
+	}
+	resize()
+	winch := make(chan os.Signal, 1)
+	signal.Notify(winch, syscall.SIGWINCH)
+	defer signal.Stop(winch)
+
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			select {
+			case <-t.Done():
+				return
+			case <-winch:
+				resize()
+			default:
+			}
+			// Poll rather than block on reading, so the key pressed after
+			// the script exits is not swallowed
+			fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+			n, err := unix.Poll(fds, 100)
+			if errors.Is(err, unix.EINTR) || n == 0 {
+				continue
+			} else if err != nil {
+				return
+			}
+			n, err = os.Stdin.Read(buf)
+			if err != nil {
+				return
+			}
+			t.Write(buf[:n])
+		}
+	}()
+
+	for p := range t.Output(context.Background()) {
+		os.Stdout.Write(p)
+	}
+}
+
+// cmdPrivilegedHelper is started as root by pkexec to execute the
+// privileged actions of a run, see [executor.ServePrivileged].
+func cmdPrivilegedHelper(args []string) int {
+	fs := newFlagSet("privileged-helper", "", "Execute privileged actions for another yafti process. Not meant to be run by hand.")
+	common := addCommonFlags(fs)
+	grace := fs.Duration("grace-period", executor.DefaultGracePeriod, "time interrupted scripts get to exit")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	// The helper runs as root, whoever started it must not pick its scripts
+	if err := config.CheckRootOwned(common.configPath); err != nil {
+		fmt.Fprintf(os.Stderr, "yafti: refusing config %s: %v\n", common.configPath, err)
+		return exitConfig
+	}
+	if code, ok := common.setup(); !ok {
+		return code
+	}
+
+	// Interrupt the script in progress cleanly if stopped
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := executor.ServePrivileged(ctx, config.ConfStatus, *grace, os.Stdin, os.Stdout); err != nil {
+		log.Error(err)
+		return exitError
+	}
+	return exitOK
+}
+
+// printPlan prints what a run would do, one step after another.
+func printPlan(plan executor.Plan, asJSON bool) int {
+	if asJSON {
+		return printJSON(plan)
+	}
+
+	for i, step := range plan.Steps {
+		var notes []string
+		if step.Skipped {
+			notes = append(notes, "already applied, skipped")
+		}
+		if step.Privileged {
+			notes = append(notes, "needs administrator privileges")
+		}
+		if step.RebootAfter {
+			notes = append(notes, "reboot after")
+		}
+		if step.Interactive {
+			notes = append(notes, "asks for input")
+		}
+		if plan.Parallel(i) {
+			notes = append(notes, "in parallel")
+		}
+		if step.AfterReboot {
+			notes = append(notes, "after the reboot")
+		}
+		fmt.Printf("%d. %s (%s)", i+1, step.Title, step.ID)
+		if len(notes) > 0 {
+			fmt.Printf(" [%s]", strings.Join(notes, ", "))
+		}
+		fmt.Println()
+		for line := range strings.Lines(step.Script) {
+			fmt.Printf("   $ %s", line)
+		}
+		fmt.Println()
+	}
+	fmt.Printf("%d action(s), %d reboot(s)\n", len(plan.Steps), plan.Reboots)
+	return exitOK
+}
+
+func cmdList(args []string) int {
+	fs := newFlagSet("list", "", "List the screens and actions of the config file.")
+	common := addCommonFlags(fs)
+	asJSON := fs.Bool("json", false, "print the list as JSON")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if code, ok := common.setup(); !ok {
+		return code
+	}
+
+	if *asJSON {
+		return printJSON(config.ConfStatus.Screens)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, screen := range config.ConfStatus.Screens {
+		fmt.Fprintf(w, "%s\n", screen.Title)
+		for _, act := range screen.Actions {
+			def := ""
+			if act.Default {
+				def = "(default)"
+			}
+			fmt.Fprintf(w, "  %s\t%s\t%s\n", act.ID, act.Title, def)
+		}
+	}
+	w.Flush()
+	return exitOK
+}
+
+func cmdSchema(args []string) int {
+	fs := newFlagSet("schema", "", "Print the JSON schema of the config file.")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	return printJSON(config.Schema())
+}
+
+func cmdVersion(args []string) int {
+	fs := newFlagSet("version", "", "Print the version.")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	v := version
+	if info, ok := debug.ReadBuildInfo(); ok && v == "dev" {
+		for _, s := range info.Settings {
+			if s.Key == "vcs.revision" {
+				v += " (" + s.Value + ")"
+			}
+		}
+	}
+	fmt.Printf("yafti %s\n", v)
+	return exitOK
+}
+
+// flagSet reports whether the flag name was given on the command line.
+func flagSet(fs *flag.FlagSet, name string) bool {
+	found := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			found = true
+		}
+	})
+	return found
+}
+
+// envDuration parses the environment variable key as a duration, or
+// returns def if unset or invalid.
+func envDuration(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Warnf("Ignoring invalid %s: %v", key, err)
+		return def
+	}
+	return d
+}
+
+func printJSON(v any) int {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		fmt.Fprintf(os.Stderr, "yafti: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
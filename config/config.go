@@ -1,27 +1,26 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"iter"
-	"log"
 	"os"
+	"regexp"
 	"slices"
-	"sync/atomic"
+	"time"
 
 	"github.com/goccy/go-yaml"
+	"github.com/labstack/gommon/log"
 )
 
-func init() {
-	if err := LoadConfig(); err != nil {
-		log.Panicf("Failed to load config: %v", err)
-	}
-	Inhibit.Store(false)
-}
+// Path of the config file used when none is given.
+const DefaultPath = "/usr/share/yafti/yafti.yml"
 
+// Config loaded by [LoadConfig]
 var ConfStatus *Config
 
-// Disallow closing the server even if heartbeat is not received
-var Inhibit atomic.Bool
-
 // Action represents a toggable script to be executed on the final screen
 type Action struct {
 	ID          string `json:"id,required"`
@@ -29,6 +28,170 @@ type Action struct {
 	Description string `json:"description"`
 	Default     bool   `json:"default"`
 	Script      string `json:"script"`
+	// Run the script as root. The user authenticates once per run.
+	Privileged bool `json:"privileged"`
+	// Who the script runs as, "user" (the default) or "root". "root" is
+	// the same as privileged. When yafti itself runs as root, "user"
+	// scripts run as the user of the desktop session.
+	RunAs string `json:"run_as"`
+	// Interpreter the scripts are run with, which must accept them with
+	// -c, e.g. "bash" (the default), "sh" or "python3".
+	Shell string `json:"shell"`
+	// Directory the scripts run in. Defaults to the one of yafti, or the
+	// home of the session user when running as them.
+	Workdir string `json:"workdir"`
+	// Environment variables set for the scripts, on top of the inherited ones
+	Env map[string]string `json:"env"`
+	// The script is interrupted, and failed, after running this long
+	Timeout time.Duration `json:"timeout"`
+	// Number of times a failed script is tried again, waiting twice as
+	// long every time
+	Retries int `json:"retries"`
+	// Exit codes the script succeeds with. Defaults to 0 only.
+	SuccessCodes []int `json:"success_codes"`
+	// Exit code the script uses to tell the action was already done,
+	// so it is reported as skipped
+	SkipCode *int `json:"skip_code"`
+	// Script telling whether the action is already applied. If it exits
+	// with 0, the action is skipped. It must not change the system. It
+	// runs without privileges, even for privileged actions, unless yafti
+	// itself runs as root.
+	Check string `json:"check"`
+	// Hide the action once it ran successfully, until its script changes
+	RunOnce bool `json:"run_once"`
+	// Keep showing the action after the first run setup is completed
+	AlwaysShow bool `json:"always_show"`
+	// Stop the run once the action succeeds, to let the system reboot.
+	// The remaining actions are resumed on the next login.
+	RebootAfter bool `json:"reboot_after"`
+	// The script can run alongside the ones of other parallel_safe actions
+	ParallelSafe bool `json:"parallel_safe"`
+	// Names of locks the script holds while running, so parallel actions
+	// sharing one run one after another. Scripts using rpm-ostree or the
+	// system flatpak installation take "rpm-ostree" and "system-flatpak"
+	// without declaring them.
+	Locks []string `json:"locks"`
+	// What happens when the script fails: "continue" with the next actions,
+	// "stop" the run, or "ask" the user. Defaults to the one of the config.
+	OnFailure string `json:"on_failure"`
+	// IDs of actions defined before this one, which it needs. If one of
+	// them fails in the same run, this one is not run.
+	DependsOn []string `json:"depends_on"`
+	// Script reverting what the script did
+	Undo string `json:"undo"`
+	// What is reverted when the script fails: "action" runs its undo
+	// script, "run" also reverts the actions that completed before it, in
+	// reverse order, and stops the run.
+	RollbackOnFailure string `json:"rollback_on_failure"`
+	// Script removing what the script installed, run in manage mode when
+	// the action is turned off. Defaults to the undo script.
+	Uninstall string `json:"uninstall"`
+	// Run the script in a terminal the user can type into, for scripts
+	// asking questions (e.g. with gum or read)
+	Interactive bool `json:"interactive"`
+}
+
+// Values of [Action.RunAs]
+const (
+	RunAsUser = "user"
+	RunAsRoot = "root"
+)
+
+// Values of [Action.OnFailure] and [Config.OnFailure]
+const (
+	OnFailureContinue = "continue"
+	OnFailureStop     = "stop"
+	OnFailureAsk      = "ask"
+)
+
+// Values of [Action.RollbackOnFailure]
+const (
+	RollbackAction = "action"
+	RollbackRun    = "run"
+)
+
+// DefaultShell is the interpreter scripts are run with by default.
+const DefaultShell = "bash"
+
+// Interpreter returns the interpreter the scripts of the action run with.
+func (a Action) Interpreter() string {
+	if a.Shell == "" {
+		return DefaultShell
+	}
+	return a.Shell
+}
+
+// Succeeded reports whether code is a successful exit code of the script.
+func (a Action) Succeeded(code int) bool {
+	if len(a.SuccessCodes) == 0 {
+		return code == 0
+	}
+	return slices.Contains(a.SuccessCodes, code)
+}
+
+// Skipped reports whether code tells the action was already done.
+func (a Action) Skipped(code int) bool {
+	return a.SkipCode != nil && *a.SkipCode == code
+}
+
+// AsRoot reports whether the script of the action must run as root.
+func (a Action) AsRoot() bool {
+	return a.Privileged || a.RunAs == RunAsRoot
+}
+
+// UndoAction returns the action running the undo script of a, the same
+// way as its script.
+func (a Action) UndoAction() Action {
+	return a.scriptAction("Revert "+a.Title, a.Undo)
+}
+
+// UninstallAction returns the action running the uninstall script of a,
+// or its undo script if it has none, the same way as its script.
+func (a Action) UninstallAction() Action {
+	return a.scriptAction("Remove "+a.Title, a.RemovalScript())
+}
+
+// RemovalScript returns the script removing what a installed, if any.
+func (a Action) RemovalScript() string {
+	if a.Uninstall != "" {
+		return a.Uninstall
+	}
+	return a.Undo
+}
+
+// scriptAction returns an action with the same ID and execution settings
+// as a, running script instead.
+func (a Action) scriptAction(title, script string) Action {
+	return Action{
+		ID:          a.ID,
+		Title:       title,
+		Script:      script,
+		Privileged:  a.Privileged,
+		RunAs:       a.RunAs,
+		Shell:       a.Shell,
+		Workdir:     a.Workdir,
+		Env:         a.Env,
+		Timeout:     a.Timeout,
+		Retries:     a.Retries,
+		Locks:       a.Locks,
+		Interactive: a.Interactive,
+	}
+}
+
+// Hash identifies the current version of the action's script, so results
+// recorded for an older version can be told apart.
+func (a Action) Hash() string {
+	sum := sha256.Sum256([]byte(a.Script))
+	return hex.EncodeToString(sum[:])
+}
+
+// Commands that make a script ask for administrator privileges by itself
+var privilegedCommand = regexp.MustCompile(`(^|[\s;&|(])(sudo|pkexec|run0|doas)\s`)
+
+// AsksForPrivileges reports whether script asks for administrator
+// privileges by itself, with sudo or a similar command.
+func AsksForPrivileges(script string) bool {
+	return privilegedCommand.MatchString(script)
 }
 
 // GetActionByID searches for an Action with the given ID in the slice of Actions.
@@ -77,11 +240,25 @@ type Screen struct {
 	Title       string   `json:"title"`
 	Description string   `json:"description"`
 	Actions     []Action `json:"actions,required"`
+	// Same as setting run_once on every action of the screen
+	RunOnce bool `json:"run_once"`
+	// Keep showing the screen after the first run setup is completed
+	AlwaysShow bool `json:"always_show"`
 }
 
 // Unmarshaled config file
 type Config struct {
 	Screens []Screen `json:"screens,required"`
+	// Shut down the server after no client is connected for this long.
+	// Overridden by the --idle-timeout flag.
+	IdleTimeout time.Duration `json:"idle_timeout"`
+	// Number of past runs kept in the history. Defaults to 50.
+	HistoryLimit int `json:"history_limit"`
+	// Number of parallel_safe actions run at once. Defaults to 4.
+	MaxParallel int `json:"max_parallel"`
+	// What happens when a script fails, for actions that don't say.
+	// Defaults to "continue".
+	OnFailure string `json:"on_failure"`
 }
 
 func (c *Config) GetAllActions() iter.Seq[Action] {
@@ -96,6 +273,16 @@ func (c *Config) GetAllActions() iter.Seq[Action] {
 	}
 }
 
+// Hash identifies the current version of the config, from the IDs and
+// scripts of its actions.
+func (c *Config) Hash() string {
+	h := sha256.New()
+	for act := range c.GetAllActions() {
+		fmt.Fprintf(h, "%s\x00%s\x00", act.ID, act.Hash())
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
 func (c *Config) GetActionsByIds(ids []string) ([]Action, bool) {
 	res := []Action{}
 	for act := range c.GetAllActions() {
@@ -108,27 +295,116 @@ func (c *Config) GetActionsByIds(ids []string) ([]Action, bool) {
 	return res, len(res) > 0
 }
 
-func LoadConfig() error {
-	var configPath string
-	if envPath := os.Getenv("YAFTI_CONF"); envPath != "" {
-		configPath = envPath
-	} else {
-		configPath = "/usr/share/yafti/yafti.yml"
+// Validate checks the config for mistakes that would break the UI or
+// make actions ambiguous. All problems found are reported at once.
+func (c *Config) Validate() error {
+	var errs []error
+	seen := make(map[string]bool)
+
+	if len(c.Screens) == 0 {
+		errs = append(errs, errors.New("no screens defined"))
+	}
+	if c.HistoryLimit < 0 {
+		errs = append(errs, errors.New("history_limit is negative"))
+	}
+	if c.MaxParallel < 0 {
+		errs = append(errs, errors.New("max_parallel is negative"))
+	}
+	if !validOnFailure(c.OnFailure) {
+		errs = append(errs, fmt.Errorf("on_failure must be %q, %q or %q", OnFailureContinue, OnFailureStop, OnFailureAsk))
+	}
+
+	for i, screen := range c.Screens {
+		if screen.Title == "" {
+			errs = append(errs, fmt.Errorf("screens[%d]: title is empty", i))
+		}
+		for j, act := range screen.Actions {
+			where := fmt.Sprintf("screens[%d].actions[%d]", i, j)
+			switch {
+			case act.ID == "":
+				errs = append(errs, fmt.Errorf("%s: id is empty", where))
+			case seen[act.ID]:
+				errs = append(errs, fmt.Errorf("%s: duplicated id %q", where, act.ID))
+			}
+			seen[act.ID] = true
+			if act.Title == "" {
+				errs = append(errs, fmt.Errorf("%s: title is empty", where))
+			}
+			if act.Timeout < 0 {
+				errs = append(errs, fmt.Errorf("%s: timeout is negative", where))
+			}
+			if act.Retries < 0 {
+				errs = append(errs, fmt.Errorf("%s: retries is negative", where))
+			}
+			// Checks also run to preview and list what is installed, where
+			// nobody is asked for a password
+			if AsksForPrivileges(act.Check) {
+				errs = append(errs, fmt.Errorf("%s: check can't ask for administrator privileges, it runs without them", where))
+			}
+			if act.SkipCode != nil && act.Succeeded(*act.SkipCode) {
+				errs = append(errs, fmt.Errorf("%s: skip_code %d is also a success code", where, *act.SkipCode))
+			}
+			switch {
+			case act.RunAs != "" && act.RunAs != RunAsUser && act.RunAs != RunAsRoot:
+				errs = append(errs, fmt.Errorf("%s: run_as must be %q or %q", where, RunAsUser, RunAsRoot))
+			case act.RunAs == RunAsUser && act.Privileged:
+				errs = append(errs, fmt.Errorf("%s: privileged conflicts with run_as %q", where, RunAsUser))
+			}
+			if !validOnFailure(act.OnFailure) {
+				errs = append(errs, fmt.Errorf("%s: on_failure must be %q, %q or %q", where, OnFailureContinue, OnFailureStop, OnFailureAsk))
+			}
+			switch act.RollbackOnFailure {
+			case "", RollbackRun:
+			case RollbackAction:
+				if act.Undo == "" {
+					errs = append(errs, fmt.Errorf("%s: rollback_on_failure %q needs an undo script", where, RollbackAction))
+				}
+			default:
+				errs = append(errs, fmt.Errorf("%s: rollback_on_failure must be %q or %q", where, RollbackAction, RollbackRun))
+			}
+			for _, dep := range act.DependsOn {
+				if !seen[dep] || dep == act.ID {
+					errs = append(errs, fmt.Errorf("%s: depends_on %q is not an action defined before it", where, dep))
+				}
+			}
+		}
 	}
 
-	data, err := os.ReadFile(configPath)
+	return errors.Join(errs...)
+}
+
+func validOnFailure(s string) bool {
+	return s == "" || s == OnFailureContinue || s == OnFailureStop || s == OnFailureAsk
+}
+
+// Load reads and parses the config file at path.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	// Log the contents of the config file for debugging
-	log.Printf("Loaded config file contents: %s", string(data))
+	log.Debugf("Loaded config file contents: %s", string(data))
 
 	var config Config
 	err = yaml.Unmarshal(data, &config)
 	if err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+// LoadConfig loads and validates the config file at path into [ConfStatus].
+func LoadConfig(path string) error {
+	config, err := Load(path)
+	if err != nil {
+		return err
+	}
+	if err := config.Validate(); err != nil {
 		return err
 	}
 
-	ConfStatus = &config
+	ConfStatus = config
 	return nil
 }
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	skip := 0
+	for _, tt := range []struct {
+		name   string
+		change func(c *Config)
+		want   string // Part of the error, none if empty
+	}{
+		{"valid", func(c *Config) {}, ""},
+		{"no screens", func(c *Config) { c.Screens = nil }, "no screens defined"},
+		{"negative max_parallel", func(c *Config) { c.MaxParallel = -1 }, "max_parallel is negative"},
+		{"unknown on_failure", func(c *Config) { c.OnFailure = "retry" }, "on_failure must be"},
+		{"empty screen title", func(c *Config) { c.Screens[0].Title = "" }, "screens[0]: title is empty"},
+		{"empty id", func(c *Config) { c.Screens[0].Actions[0].ID = "" }, "screens[0].actions[0]: id is empty"},
+		{"duplicated id", func(c *Config) { c.Screens[0].Actions[1].ID = "first" }, `screens[0].actions[1]: duplicated id "first"`},
+		{"negative timeout", func(c *Config) { c.Screens[0].Actions[0].Timeout = -1 }, "timeout is negative"},
+		{"check with sudo", func(c *Config) { c.Screens[0].Actions[0].Check = "sudo rpm -q foo" }, "check can't ask for administrator privileges"},
+		{"check using pkexec after &&", func(c *Config) { c.Screens[0].Actions[0].Check = "true && pkexec test -e /x" }, "check can't ask for administrator privileges"},
+		{"check mentioning sudo in a word", func(c *Config) { c.Screens[0].Actions[0].Check = "rpm -q sudoers-extra" }, ""},
+		{"skip_code is a success code", func(c *Config) { c.Screens[0].Actions[0].SkipCode = &skip }, "skip_code 0 is also a success code"},
+		{"unknown run_as", func(c *Config) { c.Screens[0].Actions[0].RunAs = "admin" }, "run_as must be"},
+		{"privileged user action", func(c *Config) {
+			c.Screens[0].Actions[0].RunAs = RunAsUser
+			c.Screens[0].Actions[0].Privileged = true
+		}, "privileged conflicts with run_as"},
+		{"rollback without undo", func(c *Config) { c.Screens[0].Actions[0].RollbackOnFailure = RollbackAction }, "needs an undo script"},
+		{"depends on a later action", func(c *Config) { c.Screens[0].Actions[0].DependsOn = []string{"second"} }, `depends_on "second" is not an action defined before it`},
+		{"depends on itself", func(c *Config) { c.Screens[0].Actions[1].DependsOn = []string{"second"} }, `depends_on "second"`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Config{Screens: []Screen{{
+				Title: "Screen",
+				Actions: []Action{
+					{ID: "first", Title: "First", Script: "true"},
+					{ID: "second", Title: "Second", Script: "true", DependsOn: []string{"first"}},
+				},
+			}}}
+			tt.change(c)
+
+			err := c.Validate()
+			switch {
+			case tt.want == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.want != "" && err == nil:
+				t.Errorf("no error, want %q", tt.want)
+			case tt.want != "" && !strings.Contains(err.Error(), tt.want):
+				t.Errorf("got %q, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// CheckRootOwned returns an error unless only root can change the config
+// file at path: the file and every directory above it must be owned by
+// root, and not writable by group or others. Directories writable by
+// everyone are accepted if sticky, like /tmp, as others can't replace the
+// file in them.
+//
+// The privileged helper refuses other config files, which would let any
+// user pick the scripts it runs as root.
+func CheckRootOwned(path string) error {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	path, err = filepath.EvalSymlinks(path)
+	if err != nil {
+		return err
+	}
+
+	for p := path; ; p = filepath.Dir(p) {
+		info, err := os.Stat(p)
+		if err != nil {
+			return err
+		}
+		st, ok := info.Sys().(*syscall.Stat_t)
+		if !ok {
+			return fmt.Errorf("%s: can't tell its owner", p)
+		}
+		if st.Uid != 0 {
+			return fmt.Errorf("%s is not owned by root", p)
+		}
+		mode := info.Mode()
+		if mode.Perm()&0o022 != 0 && !(mode.IsDir() && mode&fs.ModeSticky != 0) {
+			return fmt.Errorf("%s is writable by users other than root", p)
+		}
+
+		if p == filepath.Dir(p) {
+			return nil
+		}
+	}
+}
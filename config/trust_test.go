@@ -0,0 +1,85 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckRootOwned(t *testing.T) {
+	// Files created by the test are only owned by root when it runs as root
+	root := os.Geteuid() == 0
+
+	for _, tt := range []struct {
+		name    string
+		file    func(t *testing.T, dir string) string // Returns the config path
+		wantErr bool
+	}{
+		{"system file", func(t *testing.T, dir string) string {
+			if _, err := os.Stat("/etc/passwd"); err != nil {
+				t.Skip("no /etc/passwd")
+			}
+			return "/etc/passwd"
+		}, false},
+		{"private file", func(t *testing.T, dir string) string {
+			return writeConfig(t, dir, 0o755, 0o644)
+		}, !root},
+		{"file writable by others", func(t *testing.T, dir string) string {
+			return writeConfig(t, dir, 0o755, 0o666)
+		}, true},
+		{"directory writable by group", func(t *testing.T, dir string) string {
+			return writeConfig(t, dir, 0o775, 0o644)
+		}, true},
+		{"sticky directory writable by everyone", func(t *testing.T, dir string) string {
+			return writeConfig(t, dir, 0o777|os.ModeSticky, 0o644)
+		}, !root},
+		{"symlink to a file writable by others", func(t *testing.T, dir string) string {
+			target := writeConfig(t, dir, 0o755, 0o666)
+			link := filepath.Join(dir, "link.yml")
+			if err := os.Symlink(target, link); err != nil {
+				t.Fatal(err)
+			}
+			return link
+		}, true},
+		{"missing file", func(t *testing.T, dir string) string {
+			return filepath.Join(dir, "missing.yml")
+		}, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.Chmod(dir, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			path := tt.file(t, dir)
+
+			err := CheckRootOwned(path)
+			if tt.wantErr && err == nil {
+				t.Errorf("%s accepted", path)
+			} else if !tt.wantErr && err != nil {
+				t.Errorf("%s refused: %v", path, err)
+			}
+		})
+	}
+}
+
+// writeConfig writes a config file in a new directory of dir, with the
+// given modes, and returns its path.
+func writeConfig(t *testing.T, dir string, dirMode, fileMode os.FileMode) string {
+	t.Helper()
+	sub := filepath.Join(dir, "conf")
+	if err := os.Mkdir(sub, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(sub, "yafti.yml")
+	if err := os.WriteFile(path, []byte("screens: []\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// Set explicitly, the umask would mask them at creation
+	if err := os.Chmod(path, fileMode); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(sub, dirMode); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
@@ -0,0 +1,45 @@
+package executor
+
+import (
+	"github.com/Zeglius/yafti-go/internal/logind"
+	"github.com/godbus/dbus/v5"
+	"github.com/labstack/gommon/log"
+)
+
+// Runs keep the system from sleeping, going idle or shutting down until
+// they finish, with a logind inhibitor lock. Without logind, they just
+// run without it.
+
+// keepAwake takes the inhibitor lock of the run with the given ID, and
+// returns the function releasing it.
+func (x *Executor) keepAwake(id string) (release func()) {
+	conn, err := x.systemBus()
+	var lock *logind.Lock
+	if err == nil {
+		what := []string{logind.Sleep, logind.Idle, logind.Shutdown}
+		lock, err = logind.Inhibit(conn, what, "yafti", "Installing the selected items")
+	}
+	if err != nil {
+		log.Warnf("Run %s: failed to keep the system awake: %v", id, err)
+		return func() {}
+	}
+
+	return func() {
+		if err := lock.Release(); err != nil {
+			log.Warnf("Run %s: failed to release inhibitor lock: %v", id, err)
+		}
+	}
+}
+
+// systemBus returns the connection to the system bus, made the first
+// time it is needed.
+func (x *Executor) systemBus() (*dbus.Conn, error) {
+	x.busOnce.Do(func() {
+		connect := x.SystemBus
+		if connect == nil {
+			connect = func() (*dbus.Conn, error) { return dbus.ConnectSystemBus() }
+		}
+		x.bus, x.busErr = connect()
+	})
+	return x.bus, x.busErr
+}
@@ -0,0 +1,115 @@
+package executor
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Zeglius/yafti-go/config"
+	"github.com/Zeglius/yafti-go/internal/dbustest"
+	"github.com/godbus/dbus/v5"
+)
+
+// fakeLogind hands out the write end of a pipe as inhibitor lock, and
+// gives the test the other end to watch for the lock being released.
+type fakeLogind struct {
+	locks chan fakeLock
+}
+
+type fakeLock struct {
+	what, mode string
+	r, w       *os.File
+}
+
+func (l *fakeLogind) Inhibit(what, who, why, mode string) (dbus.UnixFD, *dbus.Error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return 0, dbus.MakeFailedError(err)
+	}
+	l.locks <- fakeLock{what: what, mode: mode, r: r, w: w}
+	return dbus.UnixFD(w.Fd()), nil
+}
+
+func TestRunHoldsInhibitorLock(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		end  func(r *Run, dir string)
+	}{
+		{"finished", func(r *Run, dir string) {
+			os.WriteFile(filepath.Join(dir, "go"), nil, 0o644)
+		}},
+		{"interrupted", func(r *Run, dir string) {
+			r.Cancel()
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := dbustest.Start(t)
+			logind := &fakeLogind{locks: make(chan fakeLock, 1)}
+			stub := dbustest.Connect(t, addr)
+			if err := stub.Export(logind, "/org/freedesktop/login1", "org.freedesktop.login1.Manager"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := stub.RequestName("org.freedesktop.login1", 0); err != nil {
+				t.Fatal(err)
+			}
+
+			x := New()
+			x.GracePeriod = 100 * time.Millisecond
+			x.SystemBus = func() (*dbus.Conn, error) { return dbustest.Connect(t, addr), nil }
+
+			// Waits for the test to let it finish
+			dir := t.TempDir()
+			act := config.Action{ID: "wait", Title: "Wait", Script: "while [ ! -e go ]; do sleep 0.05; done", Workdir: dir}
+			if x.Root() {
+				act.RunAs = "root"
+			}
+			r, err := x.Start([]config.Action{act})
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(r.Cancel)
+
+			var lock fakeLock
+			select {
+			case lock = <-logind.locks:
+			case <-time.After(5 * time.Second):
+				t.Fatal("no inhibitor lock taken for the run")
+			}
+			if lock.what != "sleep:idle:shutdown" || lock.mode != "block" {
+				t.Errorf("lock taken with what %q and mode %q", lock.what, lock.mode)
+			}
+
+			// The lock is in the hands of yafti once the script runs
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			for ev := range r.Events(ctx) {
+				if ev.Kind == ActionStarted {
+					break
+				}
+			}
+			lock.w.Close()
+
+			released := make(chan struct{})
+			go func() {
+				defer close(released)
+				io.Copy(io.Discard, lock.r)
+			}()
+
+			select {
+			case <-released:
+				t.Fatal("lock released while the run is in progress")
+			case <-time.After(200 * time.Millisecond):
+			}
+
+			tt.end(r, dir)
+			select {
+			case <-released:
+			case <-time.After(5 * time.Second):
+				t.Fatal("lock not released once the run ended")
+			}
+		})
+	}
+}
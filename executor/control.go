@@ -0,0 +1,56 @@
+package executor
+
+import (
+	"cmp"
+	"strconv"
+	"strings"
+)
+
+// Scripts can report more than plain output with control lines, which are
+// turned into events instead of being shown as is:
+//
+//	::progress 40                          ActionProgress, in percents
+//	::status Downloading image             ActionStatus
+//	::warning Reboot before using Waydroid ActionWarning
+//	::link Sunshine UI|https://localhost:47990 ActionLink
+//
+// Lines starting with "::" that are not one of these, or links to other
+// than http(s) URLs, are regular output.
+
+// parseControl parses line as a control line. The returned event has no
+// index yet.
+func parseControl(line string) (Event, bool) {
+	cmd, ok := strings.CutPrefix(line, "::")
+	if !ok {
+		return Event{}, false
+	}
+	name, arg, _ := strings.Cut(cmd, " ")
+	arg = strings.TrimSpace(arg)
+
+	switch name {
+	case "progress":
+		n, err := strconv.Atoi(strings.TrimSuffix(arg, "%"))
+		if err != nil {
+			return Event{}, false
+		}
+		return Event{Kind: ActionProgress, Progress: min(max(n, 0), 100)}, true
+	case "status":
+		return Event{Kind: ActionStatus, Text: arg}, true
+	case "warning":
+		if arg == "" {
+			return Event{}, false
+		}
+		return Event{Kind: ActionWarning, Text: arg}, true
+	case "link":
+		title, url, ok := strings.Cut(arg, "|")
+		if !ok {
+			title, url = arg, arg
+		}
+		title, url = strings.TrimSpace(title), strings.TrimSpace(url)
+		if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+			return Event{}, false
+		}
+		return Event{Kind: ActionLink, Text: cmp.Or(title, url), URL: url}, true
+	}
+	return Event{}, false
+}
@@ -0,0 +1,35 @@
+package executor
+
+import "testing"
+
+func TestParseControl(t *testing.T) {
+	for _, tt := range []struct {
+		line string
+		want Event
+		ok   bool
+	}{
+		{"::progress 40", Event{Kind: ActionProgress, Progress: 40}, true},
+		{"::progress 75%", Event{Kind: ActionProgress, Progress: 75}, true},
+		{"::progress 250", Event{Kind: ActionProgress, Progress: 100}, true},
+		{"::progress -3", Event{Kind: ActionProgress, Progress: 0}, true},
+		{"::progress half", Event{}, false},
+		{"::status   Downloading image  ", Event{Kind: ActionStatus, Text: "Downloading image"}, true},
+		{"::status", Event{Kind: ActionStatus}, true},
+		{"::warning Reboot before using Waydroid", Event{Kind: ActionWarning, Text: "Reboot before using Waydroid"}, true},
+		{"::warning", Event{}, false},
+		{"::link Sunshine UI|https://localhost:47990", Event{Kind: ActionLink, Text: "Sunshine UI", URL: "https://localhost:47990"}, true},
+		{"::link https://example.org", Event{Kind: ActionLink, Text: "https://example.org", URL: "https://example.org"}, true},
+		{"::link |http://localhost:8080", Event{Kind: ActionLink, Text: "http://localhost:8080", URL: "http://localhost:8080"}, true},
+		{"::link Run me|javascript:alert(1)", Event{}, false},
+		{"::link Local file|file:///etc/passwd", Event{}, false},
+		{"::unknown something", Event{}, false},
+		{"progress 40", Event{}, false},
+		{" ::progress 40", Event{}, false},
+		{"", Event{}, false},
+	} {
+		got, ok := parseControl(tt.line)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("parseControl(%q) = %+v, %v, want %+v, %v", tt.line, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package executor
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Zeglius/yafti-go/internal/session"
+	"github.com/Zeglius/yafti-go/internal/xdg"
+)
+
+// Every script gets a standard environment telling it about the run:
+//
+//	YAFTI_ACTION_ID     ID of its action
+//	YAFTI_RUN_ID        ID of the run
+//	YAFTI_SELECTED_IDS  IDs of every action of the run, separated by spaces
+//	YAFTI_CONFIG_DIR    Directory of the config file
+//	YAFTI_STATE_DIR     Directory where yafti keeps its state
+//	YAFTI_SCRATCH_DIR   Directory for temporary files, removed after the run
+//	YAFTI_OUTPUT        File the script can write key=value lines to
+//
+// Outputs written by earlier actions are exported to the later ones, as
+// YAFTI_OUTPUT_<ACTION ID>_<KEY>.
+
+// newScratch creates the scratch directory of a run. When scripts run as
+// the session user, it is theirs.
+func newScratch(user *session.User) (string, error) {
+	dir, err := os.MkdirTemp("", "yafti-run-")
+	if err != nil {
+		return "", err
+	}
+	outputs := filepath.Join(dir, "outputs")
+	if err := os.Mkdir(outputs, 0o700); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	if user != nil {
+		for _, d := range []string{dir, outputs} {
+			if err := os.Chown(d, int(user.UID), int(user.GID)); err != nil {
+				os.RemoveAll(dir)
+				return "", err
+			}
+		}
+	}
+	return dir, nil
+}
+
+// outputPath returns the file the script of the action at index i writes
+// its outputs to, or "" if there is no scratch directory.
+func (r *Run) outputPath(i int) string {
+	if r.scratch == "" {
+		return ""
+	}
+	return filepath.Join(r.scratch, "outputs", strconv.Itoa(i))
+}
+
+// env returns the standard environment of the scripts of the action at index i.
+func (r *Run) env(i int) []string {
+	ids := make([]string, len(r.Actions))
+	for j, act := range r.Actions {
+		ids[j] = act.ID
+	}
+
+	env := []string{
+		"YAFTI_ACTION_ID=" + r.Actions[i].ID,
+		"YAFTI_RUN_ID=" + r.ID,
+		"YAFTI_SELECTED_IDS=" + strings.Join(ids, " "),
+		"YAFTI_CONFIG_DIR=" + r.configDir,
+	}
+	if dir, err := xdg.StateDir(); err == nil {
+		env = append(env, "YAFTI_STATE_DIR="+dir)
+	}
+	if r.scratch != "" {
+		env = append(env, "YAFTI_SCRATCH_DIR="+r.scratch, "YAFTI_OUTPUT="+r.outputPath(i))
+	}
+
+	r.mu.Lock()
+	env = append(env, r.outputs...)
+	r.mu.Unlock()
+	return env
+}
+
+// resetOutput empties the output file of the action at index i, before
+// its script runs.
+func (r *Run) resetOutput(i int) error {
+	path := r.outputPath(i)
+	if path == "" {
+		return nil
+	}
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		return err
+	}
+	if r.user != nil {
+		return os.Chown(path, int(r.user.UID), int(r.user.GID))
+	}
+	return nil
+}
+
+// collectOutputs reads the key=value lines written by the script of the
+// action at index i, for the following actions. Other lines are ignored.
+func (r *Run) collectOutputs(i int) error {
+	path := r.outputPath(i)
+	if path == "" {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var outputs []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		k, v, ok := strings.Cut(sc.Text(), "=")
+		if !ok || strings.TrimSpace(k) == "" {
+			continue
+		}
+		outputs = append(outputs, "YAFTI_OUTPUT_"+envName(r.Actions[i].ID)+"_"+envName(strings.TrimSpace(k))+"="+v)
+	}
+
+	r.mu.Lock()
+	r.outputs = append(r.outputs, outputs...)
+	r.mu.Unlock()
+	return sc.Err()
+}
+
+// envName turns s into a valid environment variable name part.
+func envName(s string) string {
+	return strings.Map(func(c rune) rune {
+		switch {
+		case c >= 'a' && c <= 'z':
+			return c - 'a' + 'A'
+		case c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+			return c
+		}
+		return '_'
+	}, s)
+}
@@ -0,0 +1,275 @@
+package executor
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Zeglius/yafti-go/config"
+	"github.com/Zeglius/yafti-go/internal/history"
+	"github.com/Zeglius/yafti-go/internal/journal"
+	"github.com/Zeglius/yafti-go/internal/session"
+	"github.com/Zeglius/yafti-go/internal/state"
+	"github.com/godbus/dbus/v5"
+	"github.com/labstack/gommon/log"
+)
+
+// Executor runs the scripts of the selected actions and keeps the
+// output of every run around, so any frontend (web or terminal) can
+// follow it.
+type Executor struct {
+	mu      sync.Mutex
+	runs    map[string]*Run
+	seq     int
+	closed  bool // Set by Shutdown, no new run is accepted afterwards
+	inhibit *Inhibitor
+	root    bool // Whether yafti runs as root
+	// Connection to the system bus, see [Executor.systemBus]
+	busOnce sync.Once
+	bus     *dbus.Conn
+	busErr  error
+
+	// Time scripts get to exit after SIGTERM when interrupted, before
+	// they are killed.
+	GracePeriod time.Duration
+	// If set, the result of every action is recorded in it
+	State *state.Store
+	// If set, every run is journaled so it can be resumed after a crash
+	// or a reboot
+	Journal *journal.Journal
+	// If set, every finished run is kept in it
+	History *history.Store
+	// Command line starting the privileged helper as root, e.g. with
+	// pkexec. Privileged actions fail if it is empty.
+	PrivilegedHelper []string
+	// User that scripts not meant for root run as, when yafti runs as root.
+	// See [session.Active].
+	SessionUser *session.User
+	// Directory of the config file, exported to scripts as YAFTI_CONFIG_DIR
+	ConfigDir string
+	// Number of parallel_safe actions of a run executed at once
+	MaxParallel int
+	// What happens when a script fails, for actions that don't say. See
+	// [config.Config.OnFailure].
+	OnFailure string
+	// Connects to the system bus, where runs take a logind inhibitor lock
+	// keeping the system awake. Defaults to [dbus.ConnectSystemBus].
+	SystemBus func() (*dbus.Conn, error)
+}
+
+// Default value of [Executor.GracePeriod]
+const DefaultGracePeriod = 10 * time.Second
+
+var (
+	// ErrShuttingDown is returned by [Executor.Start] after [Executor.Shutdown].
+	ErrShuttingDown = errors.New("executor is shutting down")
+	// ErrNeedsRoot is returned by [Executor.Start] for actions that must run
+	// as root, when yafti does not and has no privileged helper.
+	ErrNeedsRoot = errors.New("action must run as root")
+	// ErrNoSessionUser is returned by [Executor.Start] for actions that must
+	// not run as root, when yafti does and no session user is known.
+	ErrNoSessionUser = errors.New("no session user to run action as")
+)
+
+// Root reports whether yafti runs as root.
+func (x *Executor) Root() bool {
+	return x.root
+}
+
+// checkRunnable reports, with [ErrNeedsRoot] or [ErrNoSessionUser], if
+// an action can't run as the user it is meant to.
+func (x *Executor) checkRunnable(actions []config.Action) error {
+	for _, act := range actions {
+		switch {
+		case act.AsRoot() && !x.root && len(x.PrivilegedHelper) == 0:
+			return fmt.Errorf("%w: %s", ErrNeedsRoot, act.ID)
+		case !act.AsRoot() && x.root && x.SessionUser == nil:
+			return fmt.Errorf("%w: %s", ErrNoSessionUser, act.ID)
+		}
+	}
+	return nil
+}
+
+func New() *Executor {
+	return &Executor{
+		runs:        make(map[string]*Run),
+		inhibit:     newInhibitor(),
+		root:        os.Geteuid() == 0,
+		GracePeriod: DefaultGracePeriod,
+		MaxParallel: DefaultMaxParallel,
+	}
+}
+
+// Inhibitor returns the reasons the application must stay alive.
+// Every run holds one until it finishes.
+func (x *Executor) Inhibitor() *Inhibitor {
+	return x.inhibit
+}
+
+// Start executes the given actions in the background, one after another,
+// except for the parallel_safe ones.
+//
+// The run is not tied to the caller's lifetime: it keeps going until
+// every action finishes or [Run.Cancel] is called.
+func (x *Executor) Start(actions []config.Action) (*Run, error) {
+	return x.start(actions, make([]bool, len(actions)))
+}
+
+// start starts a run, see [Run.removal].
+func (x *Executor) start(actions []config.Action, removal []bool) (*Run, error) {
+	if err := x.checkRunnable(actions); err != nil {
+		return nil, err
+	}
+
+	x.mu.Lock()
+	if x.closed {
+		x.mu.Unlock()
+		return nil, ErrShuttingDown
+	}
+	x.seq++
+	id := newRunID()
+	ctx, cancel := context.WithCancel(context.Background())
+	r := newRun(id, actions, cancel)
+	r.seq = x.seq
+	r.grace = x.GracePeriod
+	r.state = x.State
+	r.history = x.History
+	r.helperCmd = x.PrivilegedHelper
+	r.root = x.root
+	r.user = x.SessionUser
+	r.configDir = x.ConfigDir
+	r.maxParallel = x.MaxParallel
+	r.onFailure = x.OnFailure
+	r.removal = removal
+	x.runs[id] = r
+	x.mu.Unlock()
+
+	// Removals are not resumable, resuming would run their scripts as
+	// regular actions
+	if x.Journal != nil && !slices.Contains(removal, true) {
+		w, err := x.Journal.Begin(id, actions)
+		if err != nil {
+			log.Warnf("Run %s: failed to start journal, it won't be resumable: %v", id, err)
+		}
+		r.journal = w
+	}
+
+	// Held before returning, so there is no window where the run exists
+	// but nothing keeps the application alive.
+	release := x.inhibit.Hold("run " + id)
+	go func() {
+		defer release()
+		awake := x.keepAwake(id)
+		defer awake()
+		r.execute(ctx)
+	}()
+
+	return r, nil
+}
+
+// Resume starts a new run with the actions left by an unfinished one,
+// and discards the journal of the latter.
+func (x *Executor) Resume(p journal.Pending) (*Run, error) {
+	r, err := x.Start(p.Remaining)
+	if err != nil {
+		return nil, err
+	}
+	if err := x.Journal.Discard(p.RunID); err != nil {
+		log.Errorf("Failed to discard journal of run %s: %v", p.RunID, err)
+	}
+	return r, nil
+}
+
+// Retry starts a new run with the actions of rec that did not succeed,
+// see [history.Record.Failed]. Removals run as removals again.
+func (x *Executor) Retry(rec history.Record) (*Run, error) {
+	return x.start(rec.Failed())
+}
+
+// Pending returns the unfinished runs of previous sessions, oldest first.
+// Runs of this session are left out, even if still in progress.
+func (x *Executor) Pending() []journal.Pending {
+	if x.Journal == nil {
+		return nil
+	}
+	pending, err := x.Journal.Pending()
+	if err != nil {
+		log.Errorf("Failed to read journal: %v", err)
+		return nil
+	}
+	return slices.DeleteFunc(pending, func(p journal.Pending) bool {
+		_, ours := x.Run(p.RunID)
+		return ours
+	})
+}
+
+// Shutdown stops accepting new runs and interrupts the ones in progress.
+// Interrupted actions are recorded with [StatusInterrupted].
+//
+// It returns once every run has finished, or ctx is done.
+func (x *Executor) Shutdown(ctx context.Context) error {
+	x.mu.Lock()
+	x.closed = true
+	x.mu.Unlock()
+
+	active := x.Active()
+	for _, r := range active {
+		r.Cancel()
+	}
+	for _, r := range active {
+		select {
+		case <-r.Done():
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		for i, res := range r.Results() {
+			if res.Status == StatusInterrupted {
+				log.Warnf("Run %s: action %q was interrupted", r.ID, r.Actions[i].ID)
+			}
+		}
+	}
+	return nil
+}
+
+// Run returns the run with the given ID, if any.
+func (x *Executor) Run(id string) (*Run, bool) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	r, ok := x.runs[id]
+	return r, ok
+}
+
+// Active returns the runs still in progress, oldest first.
+func (x *Executor) Active() []*Run {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	var res []*Run
+	for _, r := range x.runs {
+		select {
+		case <-r.done:
+		default:
+			res = append(res, r)
+		}
+	}
+	slices.SortFunc(res, func(a, b *Run) int {
+		return cmp.Compare(a.seq, b.seq)
+	})
+	return res
+}
+
+// Busy reports whether any run is still in progress.
+func (x *Executor) Busy() bool {
+	return len(x.Active()) > 0
+}
+
+// newRunID returns an ID unique across sessions, which sorts by start time.
+func newRunID() string {
+	return fmt.Sprintf("%s-%04x", time.Now().Format("20060102-150405"), rand.N(0x10000))
+}
@@ -0,0 +1,118 @@
+package executor
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Zeglius/yafti-go/config"
+)
+
+// When a script fails, the run goes on with the next actions, stops, or
+// asks the user what to do, according to the on_failure policy of the
+// action. Either way, the actions depending on a failed one are not run.
+
+// Decision is the answer of the user to [DecisionRequired].
+type Decision string
+
+const (
+	DecisionRetry Decision = "retry" // Run the failed script again
+	DecisionSkip  Decision = "skip"  // Leave the action failed, and go on
+	DecisionAbort Decision = "abort" // Don't run the remaining actions
+)
+
+// ErrNoDecision is returned by [Run.Decide] when the action is not waiting
+// for a decision.
+var ErrNoDecision = errors.New("action is not waiting for a decision")
+
+// policy returns what happens when the script of the action at index i fails.
+func (r *Run) policy(i int) string {
+	return cmp.Or(r.Actions[i].OnFailure, r.onFailure, config.OnFailureContinue)
+}
+
+// Decide answers the [DecisionRequired] event of the action at index i.
+func (r *Run) Decide(i int, d Decision) error {
+	switch d {
+	case DecisionRetry, DecisionSkip, DecisionAbort:
+	default:
+		return fmt.Errorf("invalid decision %q", d)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ch, ok := r.decisions[i]
+	if !ok {
+		return ErrNoDecision
+	}
+	delete(r.decisions, i)
+	ch <- d
+	return nil
+}
+
+// ask waits for the user to decide what to do about the failure of the
+// action at index i. The run is aborted if ctx is done first.
+func (r *Run) ask(ctx context.Context, i int) Decision {
+	ch := make(chan Decision, 1)
+	r.mu.Lock()
+	r.decisions[i] = ch
+	r.mu.Unlock()
+
+	r.emit(Event{Kind: DecisionRequired, Index: i})
+	var d Decision
+	select {
+	case d = <-ch:
+	case <-ctx.Done():
+		r.mu.Lock()
+		delete(r.decisions, i)
+		r.mu.Unlock()
+		d = DecisionAbort
+	}
+	r.emit(Event{Kind: DecisionMade, Index: i, Text: string(d)})
+	return d
+}
+
+// stop keeps the remaining actions from starting, see [Run.stopping].
+func (r *Run) stop() {
+	r.mu.Lock()
+	r.stopped = true
+	r.mu.Unlock()
+}
+
+// stopping reports whether an action failed and the run should not go on.
+func (r *Run) stopping() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stopped
+}
+
+// failedDependency waits for the actions the one at index i depends on to
+// finish, and returns the first one that did not succeed, if any.
+// Dependencies that are not part of the run are ignored.
+func (r *Run) failedDependency(ctx context.Context, i int) (config.Action, bool) {
+	for _, id := range r.Actions[i].DependsOn {
+		dep, j := config.GetActionByID(r.Actions[:i], id)
+		if j < 0 {
+			continue
+		}
+		select {
+		case <-r.actionDone[j]:
+		case <-ctx.Done():
+			return config.Action{}, false
+		}
+		r.mu.Lock()
+		ok := r.results[j].Status.OK()
+		r.mu.Unlock()
+		if !ok {
+			return dep, true
+		}
+	}
+	return config.Action{}, false
+}
+
+// notRun reports that the action at index i was not run, and why.
+func (r *Run) notRun(i int, reason string) Event {
+	ev := Event{Kind: ActionFinished, Index: i, Status: StatusNotRun, Text: reason}
+	r.emit(ev)
+	return ev
+}
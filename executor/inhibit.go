@@ -0,0 +1,77 @@
+package executor
+
+import (
+	"slices"
+	"sync"
+)
+
+// Inhibitor keeps track of the reasons yafti must not exit, such as runs
+// in progress. Each reason is held until its release function is called.
+type Inhibitor struct {
+	mu       sync.Mutex
+	holds    map[int]string // Reason of every active hold, by hold ID
+	nextID   int
+	onChange []func()
+}
+
+func newInhibitor() *Inhibitor {
+	return &Inhibitor{holds: make(map[int]string)}
+}
+
+// Hold adds a reason to stay alive, until the returned function is called.
+// Calling release more than once has no effect.
+func (i *Inhibitor) Hold(reason string) (release func()) {
+	i.mu.Lock()
+	i.nextID++
+	id := i.nextID
+	i.holds[id] = reason
+	i.mu.Unlock()
+	i.changed()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			i.mu.Lock()
+			delete(i.holds, id)
+			i.mu.Unlock()
+			i.changed()
+		})
+	}
+}
+
+// Active reports whether at least one reason is held.
+func (i *Inhibitor) Active() bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return len(i.holds) > 0
+}
+
+// Reasons returns the reasons currently held, sorted.
+func (i *Inhibitor) Reasons() []string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	res := make([]string, 0, len(i.holds))
+	for _, r := range i.holds {
+		res = append(res, r)
+	}
+	slices.Sort(res)
+	return res
+}
+
+// OnChange registers f to be called every time a reason is held or released.
+func (i *Inhibitor) OnChange(f func()) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.onChange = append(i.onChange, f)
+}
+
+func (i *Inhibitor) changed() {
+	i.mu.Lock()
+	fns := slices.Clone(i.onChange)
+	i.mu.Unlock()
+
+	for _, f := range fns {
+		f()
+	}
+}
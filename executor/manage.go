@@ -0,0 +1,59 @@
+package executor
+
+import (
+	"context"
+
+	"github.com/Zeglius/yafti-go/config"
+	"github.com/Zeglius/yafti-go/internal/state"
+)
+
+// In manage mode, the toggles show which actions are currently installed,
+// and turning one off removes it with its uninstall script.
+
+// Installed probes whether each action is currently installed, by ID: its
+// check script exits with 0 or, without one, it last ran successfully
+// according to the state file. Check scripts run as in [Executor.Plan].
+func (x *Executor) Installed(ctx context.Context, actions []config.Action) map[string]bool {
+	res := make(map[string]bool, len(actions))
+	for _, act := range actions {
+		if act.Check != "" {
+			res[act.ID] = applied(ctx, act, optionsFor(act, x.GracePeriod, x.root, x.SessionUser))
+			continue
+		}
+		if x.State != nil {
+			st, ok := x.State.Action(act.ID)
+			res[act.ID] = ok && (st.Result == state.ResultSuccess || st.Result == state.ResultSkipped)
+		}
+	}
+	return res
+}
+
+// Change starts a new run removing the actions of remove with their
+// uninstall scripts, then installing the ones of install. Removed actions
+// are recorded as reverted once their script succeeds. Actions of remove
+// without an uninstall or undo script are left out.
+func (x *Executor) Change(install, remove []config.Action) (*Run, error) {
+	return x.start(changeActions(install, remove))
+}
+
+// PlanChange is [Executor.Plan] for [Executor.Change].
+func (x *Executor) PlanChange(ctx context.Context, install, remove []config.Action) Plan {
+	actions, _ := changeActions(install, remove)
+	return x.Plan(ctx, actions)
+}
+
+// changeActions returns the actions of a run of [Executor.Change], in
+// order, and which ones are removals.
+func changeActions(install, remove []config.Action) (actions []config.Action, removal []bool) {
+	for _, act := range remove {
+		if act.RemovalScript() != "" {
+			actions = append(actions, act.UninstallAction())
+			removal = append(removal, true)
+		}
+	}
+	for _, act := range install {
+		actions = append(actions, act)
+		removal = append(removal, false)
+	}
+	return actions, removal
+}
@@ -0,0 +1,87 @@
+package executor
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/Zeglius/yafti-go/config"
+)
+
+// Actions with parallel_safe run alongside each other, up to
+// [Executor.MaxParallel] at once. The other actions run alone, once the
+// ones before them are done. Parallel actions holding the same lock still
+// run one after another.
+
+// Default value of [Executor.MaxParallel]
+const DefaultMaxParallel = 4
+
+// Locks taken implicitly by actions, see [locksOf].
+const (
+	LockRpmOstree     = "rpm-ostree"     // rpm-ostree allows a single transaction at once
+	LockSystemFlatpak = "system-flatpak" // The system flatpak installation
+	lockHelper        = "privileged-helper"
+)
+
+var (
+	rpmOstreeCommand = regexp.MustCompile(`(^|[\s;&|(])rpm-ostree\s`)
+	flatpakCommand   = regexp.MustCompile(`(^|[\s;&|(])flatpak\s`)
+)
+
+// locksOf returns the locks the script of act needs, sorted: the ones it
+// declares, and the ones guessed from the commands it uses.
+func locksOf(act config.Action) []string {
+	locks := slices.Clone(act.Locks)
+	if rpmOstreeCommand.MatchString(act.Script) {
+		locks = append(locks, LockRpmOstree)
+	}
+	if flatpakCommand.MatchString(act.Script) && !strings.Contains(act.Script, "--user") {
+		locks = append(locks, LockSystemFlatpak)
+	}
+	slices.Sort(locks)
+	return slices.Compact(locks)
+}
+
+// parallel reports whether the action at index i can run alongside others.
+func (r *Run) parallel(i int) bool {
+	return runsInParallel(r.Actions[i], r.maxParallel)
+}
+
+// runsInParallel reports whether act can run alongside others, when up
+// to maxParallel actions run at once. Actions rebooting the system never
+// do, the run stops after them.
+func runsInParallel(act config.Action, maxParallel int) bool {
+	return maxParallel > 1 && act.ParallelSafe && !act.RebootAfter
+}
+
+// lock takes the locks needed by the action at index i, and returns a
+// function releasing them. The privileged helper runs a single script at
+// once, so it is one of them.
+func (r *Run) lock(i int) (unlock func()) {
+	names := locksOf(r.Actions[i])
+	if r.Actions[i].AsRoot() && !r.root {
+		names = append(names, lockHelper)
+		slices.Sort(names)
+	}
+
+	// Always taken in the same order, so actions can't deadlock
+	mus := make([]*sync.Mutex, len(names))
+	r.mu.Lock()
+	for j, name := range names {
+		if r.locks[name] == nil {
+			r.locks[name] = new(sync.Mutex)
+		}
+		mus[j] = r.locks[name]
+	}
+	r.mu.Unlock()
+
+	for _, mu := range mus {
+		mu.Lock()
+	}
+	return func() {
+		for _, mu := range slices.Backward(mus) {
+			mu.Unlock()
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package executor
+
+import (
+	"context"
+	"strings"
+
+	"github.com/Zeglius/yafti-go/config"
+)
+
+// Step is what would happen to a single action of a run.
+type Step struct {
+	ID     string `json:"id"`
+	Title  string `json:"title"`
+	Script string `json:"script"`
+	// Whether the action needs administrator privileges
+	Privileged bool `json:"privileged"`
+	// Whether the system reboots once the action succeeds
+	RebootAfter bool `json:"reboot_after"`
+	// Whether the script asks for input in a terminal
+	Interactive bool `json:"interactive"`
+	// Whether its check found the action already applied, so it would be skipped
+	Skipped bool `json:"skipped"`
+	// Steps of the same group run at the same time. Groups run one after
+	// another.
+	Group int `json:"group"`
+	// Whether the step only runs once the system rebooted, after an
+	// earlier step with RebootAfter
+	AfterReboot bool `json:"after_reboot"`
+}
+
+// Plan is what a run would do, without changing anything.
+type Plan struct {
+	Steps []Step `json:"steps"`
+	// Number of reboots the run would go through
+	Reboots int `json:"reboots"`
+	// Whether any step asks for administrator privileges
+	Privileged bool `json:"privileged"`
+}
+
+// Plan resolves what a run of actions would do, in execution order: the
+// actions start in the given order, the parallel ones alongside the
+// parallel ones next to them, and a run stops after an action rebooting
+// the system, to go on once it rebooted. See [Run.execute].
+//
+// The check scripts of the actions are executed to know which ones would
+// be skipped, nothing else is.
+func (x *Executor) Plan(ctx context.Context, actions []config.Action) Plan {
+	p := Plan{Steps: make([]Step, 0, len(actions))}
+	group, rebooted := 0, false
+	for i, act := range actions {
+		// Parallel actions join the group of the ones right before them
+		if i > 0 && !(runsInParallel(act, x.MaxParallel) && runsInParallel(actions[i-1], x.MaxParallel)) {
+			group++
+		}
+		st := Step{
+			ID:          act.ID,
+			Title:       act.Title,
+			Script:      strings.Trim(act.Script, "\n\r\t"),
+			Privileged:  NeedsPrivileges(act),
+			RebootAfter: act.RebootAfter,
+			Interactive: act.Interactive,
+			Skipped:     applied(ctx, act, optionsFor(act, x.GracePeriod, x.root, x.SessionUser)),
+			Group:       group,
+			AfterReboot: rebooted,
+		}
+		if !st.Skipped {
+			p.Privileged = p.Privileged || st.Privileged
+			if st.RebootAfter {
+				p.Reboots++
+				rebooted = true
+			}
+		}
+		p.Steps = append(p.Steps, st)
+	}
+	return p
+}
+
+// Parallel reports whether the step at index i runs at the same time as
+// other steps.
+func (p Plan) Parallel(i int) bool {
+	g := p.Steps[i].Group
+	return i > 0 && p.Steps[i-1].Group == g || i < len(p.Steps)-1 && p.Steps[i+1].Group == g
+}
+
+// NeedsPrivileges reports whether the user will be asked for administrator
+// privileges when act runs: either it runs as root, or its script uses
+// sudo or a similar command.
+func NeedsPrivileges(act config.Action) bool {
+	return act.AsRoot() || config.AsksForPrivileges(act.Script)
+}
@@ -0,0 +1,275 @@
+package executor
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Zeglius/yafti-go/config"
+)
+
+// The privileged helper is a second yafti process, started as root with
+// pkexec the first time a run reaches a privileged action. It lives until
+// the run is over, so the user authenticates once per run.
+//
+// The helper loads the config file itself, and only executes the scripts
+// of its privileged actions and their undo and uninstall scripts, looked
+// up by action ID and hash. yafti talks to it with JSON lines over its stdin and stdout.
+
+// helperRequest is sent by yafti to the helper.
+type helperRequest struct {
+	// Run the script of the privileged action with this ID and
+	// [config.Action.Hash]. The hash tells its script from its undo and
+	// uninstall scripts.
+	ID   string `json:"id,omitempty"`
+	Hash string `json:"hash,omitempty"`
+	// Standard environment of the script, see [Run.env]. Only YAFTI_
+	// variables are kept.
+	Env []string `json:"env,omitempty"`
+	// Interrupt the script in progress
+	Cancel bool `json:"cancel,omitempty"`
+}
+
+type helperReplyKind string
+
+const (
+	helperReady helperReplyKind = "ready" // Sent once, when the helper starts
+	helperLine  helperReplyKind = "line"  // An output line of the script
+	helperExit  helperReplyKind = "exit"  // The script is over
+)
+
+// helperReply is sent by the helper to yafti.
+type helperReply struct {
+	Kind     helperReplyKind `json:"kind"`
+	Text     string          `json:"text,omitempty"`
+	ExitCode int             `json:"exit_code,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// ServePrivileged runs the privileged helper, executing the privileged
+// actions of conf requested on in, and replying on out. It returns once
+// in is closed or ctx is done, after interrupting the script in progress
+// if any.
+func ServePrivileged(ctx context.Context, conf *config.Config, grace time.Duration, in io.Reader, out io.Writer) error {
+	// By ID, as actions with the same script can still run differently
+	allowed := make(map[string][]config.Action)
+	for act := range conf.GetAllActions() {
+		if act.AsRoot() {
+			allowed[act.ID] = append(allowed[act.ID], act)
+			for _, extra := range []config.Action{act.UndoAction(), act.UninstallAction()} {
+				if extra.Script != "" {
+					allowed[act.ID] = append(allowed[act.ID], extra)
+				}
+			}
+		}
+	}
+
+	var mu sync.Mutex
+	enc := json.NewEncoder(out)
+	reply := func(r helperReply) {
+		mu.Lock()
+		defer mu.Unlock()
+		enc.Encode(r)
+	}
+
+	reqs := make(chan helperRequest)
+	go func() {
+		defer close(reqs)
+		dec := json.NewDecoder(in)
+		for {
+			var req helperRequest
+			if err := dec.Decode(&req); err != nil {
+				return
+			}
+			reqs <- req
+		}
+	}()
+
+	reply(helperReply{Kind: helperReady})
+
+	for {
+		var req helperRequest
+		select {
+		case r, ok := <-reqs:
+			if !ok {
+				return nil
+			}
+			req = r
+		case <-ctx.Done():
+			return nil
+		}
+		if req.Hash == "" {
+			continue
+		}
+		i := slices.IndexFunc(allowed[req.ID], func(act config.Action) bool {
+			return act.Hash() == req.Hash
+		})
+		if i < 0 {
+			reply(helperReply{Kind: helperExit, ExitCode: -1, Error: "script is not a privileged action of the config"})
+			continue
+		}
+		act := allowed[req.ID][i]
+
+		opts := optionsFor(act, grace, false, nil)
+		var env []string
+		for _, kv := range req.Env {
+			if strings.HasPrefix(kv, "YAFTI_") {
+				env = append(env, kv)
+			}
+		}
+		opts.env = append(env, opts.env...)
+
+		scriptCtx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			code, err := runScript(scriptCtx, act.Script, opts, func(line string) {
+				reply(helperReply{Kind: helperLine, Text: line})
+			})
+			r := helperReply{Kind: helperExit, ExitCode: code}
+			if err != nil {
+				r.Error = err.Error()
+			}
+			reply(r)
+		}()
+
+	wait:
+		for {
+			select {
+			case r, ok := <-reqs:
+				if !ok {
+					// yafti is gone, don't leave the script behind
+					cancel()
+					<-done
+					return nil
+				}
+				if r.Cancel {
+					cancel()
+				}
+			case <-ctx.Done():
+				cancel()
+				<-done
+				return nil
+			case <-done:
+				break wait
+			}
+		}
+		cancel()
+	}
+}
+
+// helper is the yafti side of a running privileged helper.
+type helper struct {
+	cmd *exec.Cmd
+	in  io.WriteCloser
+	out *json.Decoder
+	mu  sync.Mutex // Held while sending a request
+}
+
+// errHelperAuth is returned when the helper could not be started, most
+// likely because the user did not authenticate.
+var errHelperAuth = errors.New("administrator authentication failed or was cancelled")
+
+// startHelper launches the helper with the given command line, and waits
+// for it to be ready, which includes the user authenticating. If ctx is
+// cancelled meanwhile, the helper is stopped and ctx.Err() returned.
+func startHelper(ctx context.Context, command []string) (*helper, error) {
+	if len(command) == 0 {
+		return nil, errors.New("privileged actions are not available")
+	}
+
+	cmd := exec.Command(command[0], command[1:]...)
+	// Keep terminal signals away, the helper is stopped through its stdin
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	// pkexec waits for the user at the authentication prompt. Once it runs
+	// the helper as root, it can't be killed anymore, but the helper exits
+	// as soon as it reads the end of its stdin.
+	stop := context.AfterFunc(ctx, func() {
+		in.Close()
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+	})
+	defer stop()
+
+	h := &helper{cmd: cmd, in: in, out: json.NewDecoder(bufio.NewReader(out))}
+	var r helperReply
+	if err := h.out.Decode(&r); err != nil || r.Kind != helperReady || ctx.Err() != nil {
+		in.Close()
+		cmd.Wait()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, errHelperAuth
+	}
+	return h, nil
+}
+
+func (h *helper) send(req helperRequest) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	b, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	_, err = h.in.Write(append(b, '\n'))
+	return err
+}
+
+// run executes the script of the privileged action act in the helper,
+// with the standard environment env, the same way [runScript] does.
+func (h *helper) run(ctx context.Context, act config.Action, env []string, onLine func(string)) (int, error) {
+	if err := h.send(helperRequest{ID: act.ID, Hash: act.Hash(), Env: env}); err != nil {
+		return -1, err
+	}
+
+	// Forward the cancellation, the helper does the rest
+	stop := context.AfterFunc(ctx, func() {
+		h.send(helperRequest{Cancel: true})
+	})
+	defer stop()
+
+	for {
+		var r helperReply
+		if err := h.out.Decode(&r); err != nil {
+			return -1, fmt.Errorf("privileged helper: %w", err)
+		}
+		switch r.Kind {
+		case helperLine:
+			onLine(r.Text)
+		case helperExit:
+			switch {
+			case ctx.Err() != nil:
+				return -1, ctx.Err()
+			case r.Error != "":
+				return r.ExitCode, errors.New(r.Error)
+			}
+			return r.ExitCode, nil
+		}
+	}
+}
+
+// close stops the helper.
+func (h *helper) close() error {
+	h.in.Close()
+	return h.cmd.Wait()
+}
@@ -0,0 +1,40 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Zeglius/yafti-go/config"
+	"github.com/godbus/dbus/v5"
+)
+
+func TestShutdownWhileAuthenticating(t *testing.T) {
+	x := New()
+	x.root = false // Privileged actions go through the helper, even in CI
+	x.GracePeriod = 100 * time.Millisecond
+	x.SystemBus = func() (*dbus.Conn, error) { return nil, errors.New("no system bus") }
+	// Stands for pkexec waiting for the user at the authentication prompt
+	x.PrivilegedHelper = []string{"sleep", "30"}
+
+	r, err := x.Start([]config.Action{{ID: "priv", Title: "Privileged", Script: "true", Privileged: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for ev := range r.Events(ctx) {
+		if ev.Kind == ActionStarted {
+			break
+		}
+	}
+
+	if err := x.Shutdown(ctx); err != nil {
+		t.Fatalf("shutdown waited for the authentication: %v", err)
+	}
+	if got := r.Results()[0].Status; got != StatusInterrupted {
+		t.Errorf("action recorded as %s, want %s", got, StatusInterrupted)
+	}
+}
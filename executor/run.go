@@ -0,0 +1,799 @@
+package executor
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Zeglius/yafti-go/config"
+	"github.com/Zeglius/yafti-go/internal/history"
+	"github.com/Zeglius/yafti-go/internal/journal"
+	"github.com/Zeglius/yafti-go/internal/session"
+	"github.com/Zeglius/yafti-go/internal/state"
+	"github.com/labstack/gommon/log"
+)
+
+type EventKind string
+
+const (
+	ActionStarted  EventKind = "action_started"
+	ActionOutput   EventKind = "action_output"
+	ActionFinished EventKind = "action_finished"
+	RunFinished    EventKind = "run_finished"
+	// The run stopped after the action at Index, to let the system reboot
+	RebootRequired EventKind = "reboot_required"
+
+	// Reported by the script of the action at Index with control lines,
+	// see [parseControl]
+	ActionProgress EventKind = "action_progress" // Progress is set
+	ActionStatus   EventKind = "action_status"   // Text is what the script is doing
+	ActionWarning  EventKind = "action_warning"  // Text is the warning
+	ActionLink     EventKind = "action_link"     // Text is the title of URL
+
+	// The script of the action at Index failed, and the run waits for
+	// [Run.Decide] before going on
+	DecisionRequired EventKind = "decision_required"
+	// The user answered a [DecisionRequired], Text is the [Decision]
+	DecisionMade EventKind = "decision_made"
+
+	// The undo script of the action at Index started, after the script
+	// failed or to roll back the run
+	UndoStarted EventKind = "undo_started"
+	// The undo script is over, with Status [StatusReverted] if it succeeded
+	UndoFinished EventKind = "undo_finished"
+
+	// The script of the interactive action at Index started in a terminal,
+	// see [Run.Terminal]
+	TerminalOpened EventKind = "terminal_opened"
+)
+
+type Status string
+
+const (
+	StatusPending     Status = "pending"
+	StatusRunning     Status = "running"
+	StatusSuccess     Status = "success"
+	StatusFailed      Status = "failed"
+	StatusInterrupted Status = "interrupted" // Stopped by a cancellation or shutdown
+	StatusSkipped     Status = "skipped"     // Already applied according to its check
+	StatusNotRun      Status = "not_run"     // Left out because of an earlier failure
+	StatusReverted    Status = "reverted"    // Its undo script succeeded
+)
+
+// Event is a single thing that happened during a [Run].
+type Event struct {
+	Kind EventKind
+	Time time.Time
+	// Index of the action in [Run.Actions]. Unused for [RunFinished].
+	Index int
+	// Output line for [ActionOutput], error message for [ActionFinished].
+	Text string
+	// Exit code of the script for [ActionFinished].
+	ExitCode int
+	// Outcome of the action for [ActionFinished].
+	Status Status
+	// From 0 to 100, for [ActionProgress].
+	Progress int
+	// Target of an [ActionLink].
+	URL string
+	// Number of the terminal of a [TerminalOpened].
+	Terminal int
+}
+
+// Failed reports if an [ActionFinished] event represents an unsuccessful script.
+func (ev Event) Failed() bool {
+	return !ev.Status.OK()
+}
+
+// OK reports whether the action is in the state it should be after a run:
+// it ran successfully, or was already applied.
+func (s Status) OK() bool {
+	return s == StatusSuccess || s == StatusSkipped
+}
+
+// Result is the outcome of a single action of a [Run].
+type Result struct {
+	Status   Status
+	ExitCode int
+	Error    string
+	Started  time.Time
+	Finished time.Time
+	// Last progress and status reported by the script, if any
+	Progress int
+	Message  string
+	// Warnings and links reported by the script
+	Warnings []string
+	Links    []history.Link
+}
+
+// Run is a single execution of a list of actions.
+type Run struct {
+	ID      string
+	Actions []config.Action
+
+	seq      int             // Start order, for sorting
+	grace    time.Duration   // Time scripts get to exit once interrupted
+	state    *state.Store    // Where results are recorded, if set
+	journal  *journal.Writer // Write-ahead log of the run, if set
+	history  *history.Store  // Where the run is kept once finished, if set
+	started  time.Time
+	finished time.Time
+	// Started the first time a privileged action runs, and kept until the end
+	helperCmd []string
+	helper    *helper
+	helperErr error
+	root      bool          // Whether yafti runs as root
+	user      *session.User // Who non-root scripts run as, when yafti runs as root
+	configDir string        // Exported to scripts
+	scratch   string        // Scratch directory of the run, if it could be created
+	outputs   []string      // Outputs of the actions so far, as environment variables
+	// Number of parallel_safe actions running at once
+	maxParallel int
+	locks       map[string]*sync.Mutex // By name, see [Run.lock]
+	// Default on_failure policy of the actions
+	onFailure  string
+	decisions  map[int]chan Decision // Pending decisions, by index of action
+	stopped    bool                  // Set when the remaining actions must not start
+	actionDone []chan struct{}       // Closed once the action at the same index is over
+	rollback   bool                  // Revert the completed actions once the others are over
+	// By index, whether the action reverts or removes the one with the same
+	// ID, see [Executor.Change]
+	removal []bool
+
+	mu        sync.Mutex
+	events    []Event
+	results   []Result      // Kept in sync with events, by index of action
+	terminals []*Terminal   // Of the interactive scripts, in start order
+	changed   chan struct{} // Closed and replaced every time an event is added
+	done      chan struct{}
+	cancel    context.CancelFunc
+}
+
+func newRun(id string, actions []config.Action, cancel context.CancelFunc) *Run {
+	results := make([]Result, len(actions))
+	actionDone := make([]chan struct{}, len(actions))
+	for i := range results {
+		results[i].Status = StatusPending
+		actionDone[i] = make(chan struct{})
+	}
+
+	return &Run{
+		ID:         id,
+		Actions:    actions,
+		started:    time.Now(),
+		results:    results,
+		locks:      make(map[string]*sync.Mutex),
+		decisions:  make(map[int]chan Decision),
+		actionDone: actionDone,
+		changed:    make(chan struct{}),
+		done:       make(chan struct{}),
+		cancel:     cancel,
+	}
+}
+
+// RebootRequired reports whether the run stopped to let the system reboot.
+func (r *Run) RebootRequired() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.ContainsFunc(r.events, func(ev Event) bool {
+		return ev.Kind == RebootRequired
+	})
+}
+
+// Succeeded reports whether every action of the run finished successfully.
+func (r *Run) Succeeded() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, res := range r.results {
+		if !res.Status.OK() {
+			return false
+		}
+	}
+	return len(r.results) > 0
+}
+
+// Results returns the outcome of every action so far, by index of action.
+func (r *Run) Results() []Result {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	res := slices.Clone(r.results)
+	for i := range res {
+		res[i].Warnings = slices.Clone(res[i].Warnings)
+		res[i].Links = slices.Clone(res[i].Links)
+	}
+	return res
+}
+
+// Done is closed once every action of the run has finished.
+func (r *Run) Done() <-chan struct{} {
+	return r.done
+}
+
+// Cancel interrupts the currently running script and skips the remaining ones.
+func (r *Run) Cancel() {
+	r.cancel()
+}
+
+// Events replays every event of the run so far, then follows new ones
+// until the run finishes or ctx is cancelled.
+func (r *Run) Events(ctx context.Context) <-chan Event {
+	out := make(chan Event)
+
+	go func() {
+		defer close(out)
+
+		next := 0
+		for {
+			r.mu.Lock()
+			pending := r.events[next:]
+			changed := r.changed
+			r.mu.Unlock()
+
+			for _, ev := range pending {
+				select {
+				case out <- ev:
+				case <-ctx.Done():
+					return
+				}
+				if ev.Kind == RunFinished {
+					return
+				}
+			}
+			next += len(pending)
+
+			select {
+			case <-changed:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+func (r *Run) emit(ev Event) {
+	ev.Time = time.Now()
+
+	r.mu.Lock()
+	r.events = append(r.events, ev)
+	switch ev.Kind {
+	case ActionStarted:
+		r.results[ev.Index].Status = StatusRunning
+		r.results[ev.Index].Started = ev.Time
+	case ActionFinished:
+		res := &r.results[ev.Index]
+		res.Status = ev.Status
+		res.ExitCode = ev.ExitCode
+		res.Error = ev.Text
+		res.Finished = ev.Time
+	case UndoFinished:
+		if ev.Status == StatusReverted {
+			r.results[ev.Index].Status = StatusReverted
+		}
+	case ActionProgress:
+		r.results[ev.Index].Progress = ev.Progress
+	case ActionStatus:
+		r.results[ev.Index].Message = ev.Text
+	case ActionWarning:
+		res := &r.results[ev.Index]
+		res.Warnings = append(res.Warnings, ev.Text)
+	case ActionLink:
+		res := &r.results[ev.Index]
+		res.Links = append(res.Links, history.Link{Title: ev.Text, URL: ev.URL})
+	}
+	close(r.changed)
+	r.changed = make(chan struct{})
+	r.mu.Unlock()
+}
+
+func (r *Run) execute(ctx context.Context) {
+	defer close(r.done)
+	defer r.cancel()
+
+	var err error
+	if r.scratch, err = newScratch(r.user); err != nil {
+		log.Warnf("Run %s: failed to create scratch directory: %v", r.ID, err)
+	}
+
+	rebootAfter := -1
+	var wg sync.WaitGroup
+	workers := make(chan struct{}, max(r.maxParallel, 1))
+	for i, action := range r.Actions {
+		if ctx.Err() != nil || r.stopping() {
+			break
+		}
+
+		if r.parallel(i) {
+			select {
+			case workers <- struct{}{}:
+			case <-ctx.Done():
+				continue
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-workers }()
+				r.step(ctx, i)
+			}()
+			continue
+		}
+
+		// Run alone, after the parallel actions before it
+		wg.Wait()
+		if ctx.Err() != nil || r.stopping() {
+			break
+		}
+		ev := r.step(ctx, i)
+		if action.RebootAfter && ev.Status == StatusSuccess {
+			r.emit(Event{Kind: RebootRequired, Index: i})
+			rebootAfter = i
+			break
+		}
+	}
+	wg.Wait()
+
+	if ctx.Err() == nil && r.stopping() {
+		for i, res := range r.Results() {
+			if res.Status == StatusPending {
+				r.notRun(i, "the run stopped after a failure")
+			}
+		}
+	}
+	r.mu.Lock()
+	rollback := r.rollback
+	r.mu.Unlock()
+	if rollback {
+		r.rollBack(ctx)
+	}
+
+	switch {
+	case rebootAfter >= 0 && rebootAfter < len(r.Actions)-1:
+		// Remaining actions continue after the reboot
+		r.journalErr(r.journal.RebootRequired(r.Actions[rebootAfter].ID))
+	case ctx.Err() != nil:
+		// Interrupted by a shutdown, keep the journal to resume later
+		r.journalErr(r.journal.Close())
+	default:
+		r.journalErr(r.journal.Finish())
+	}
+
+	if r.helper != nil {
+		if err := r.helper.close(); err != nil {
+			log.Warnf("Run %s: privileged helper: %v", r.ID, err)
+		}
+	}
+	if r.scratch != "" {
+		if err := os.RemoveAll(r.scratch); err != nil {
+			log.Warnf("Run %s: failed to remove scratch directory: %v", r.ID, err)
+		}
+	}
+
+	r.mu.Lock()
+	r.finished = time.Now()
+	r.mu.Unlock()
+	r.emit(Event{Kind: RunFinished})
+	r.saveHistory()
+}
+
+// step executes the action at index i, once the actions it depends on
+// are over and it holds the locks it needs, and returns the event telling
+// how it went.
+func (r *Run) step(ctx context.Context, i int) Event {
+	action := r.Actions[i]
+	defer close(r.actionDone[i])
+	if dep, ok := r.failedDependency(ctx, i); ok {
+		return r.notRun(i, fmt.Sprintf("needs %s, which did not succeed", dep.Title))
+	}
+
+	unlock := r.lock(i)
+	defer unlock()
+	switch {
+	case ctx.Err() != nil:
+		return Event{}
+	case r.stopping():
+		return r.notRun(i, "the run stopped after a failure")
+	}
+
+	r.emit(Event{Kind: ActionStarted, Index: i})
+	r.journalErr(r.journal.ActionStarted(action.ID))
+
+	var ev Event
+	if applied(ctx, action, r.options(i)) {
+		ev = Event{Kind: ActionFinished, Index: i, Status: StatusSkipped}
+		r.emit(ev)
+	} else {
+		ev = r.runUntilDecided(ctx, i, action)
+	}
+	if ev.Status != StatusInterrupted {
+		// Interrupted actions are left out, so they run again on resume
+		r.journalErr(r.journal.ActionFinished(action.ID, string(ev.Status), ev.ExitCode))
+	}
+	return ev
+}
+
+// runUntilDecided executes the script of the action at index i, and
+// applies its on_failure policy if it fails, which may run it again.
+func (r *Run) runUntilDecided(ctx context.Context, i int, action config.Action) Event {
+	for {
+		ev := r.runAction(ctx, i, action)
+		r.record(action, ev)
+		if err := r.collectOutputs(i); err != nil {
+			log.Warnf("Run %s: failed to read outputs of action %q: %v", r.ID, action.ID, err)
+		}
+		r.emit(ev)
+		if ev.Status != StatusFailed {
+			return ev
+		}
+
+		switch r.policy(i) {
+		case config.OnFailureStop:
+			r.stop()
+		case config.OnFailureAsk:
+			switch r.ask(ctx, i) {
+			case DecisionRetry:
+				r.emit(Event{Kind: ActionOutput, Index: i, Text: "Retrying"})
+				continue
+			case DecisionAbort:
+				r.stop()
+			}
+		}
+		r.onFailed(ctx, i)
+		return ev
+	}
+}
+
+// Record returns the outcome and output of the run so far, as kept in the history.
+func (r *Run) Record() history.Record {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rec := history.Record{ID: r.ID, Started: r.started, Finished: r.finished}
+	if rec.Finished.IsZero() {
+		rec.Finished = time.Now()
+	}
+	for i, act := range r.Actions {
+		res := r.results[i]
+		rec.Actions = append(rec.Actions, history.ActionRecord{
+			Action:   act,
+			Status:   string(res.Status),
+			ExitCode: res.ExitCode,
+			Error:    res.Error,
+			Started:  res.Started,
+			Finished: res.Finished,
+			Warnings: slices.Clone(res.Warnings),
+			Links:    slices.Clone(res.Links),
+			Removal:  r.removal[i],
+		})
+	}
+	for _, ev := range r.events {
+		if ev.Kind == ActionOutput {
+			out := &rec.Actions[ev.Index].Output
+			*out = append(*out, ev.Text)
+		}
+	}
+	return rec
+}
+
+// saveHistory stores the outcome and output of the run in the history, if any.
+func (r *Run) saveHistory() {
+	if r.history == nil {
+		return
+	}
+	if err := r.history.Save(r.Record()); err != nil {
+		log.Errorf("Run %s: failed to save history: %v", r.ID, err)
+	}
+}
+
+// journalErr logs a failure to write the journal. It only costs the
+// ability to resume, so the run goes on.
+func (r *Run) journalErr(err error) {
+	if err != nil {
+		log.Errorf("Run %s: failed to write journal: %v", r.ID, err)
+	}
+}
+
+// Delay before the first retry of a failed script, doubled for every
+// following one, up to maxRetryDelay.
+const (
+	retryDelay    = 2 * time.Second
+	maxRetryDelay = time.Minute
+)
+
+// runAction executes the script of the action at index i, trying again
+// as many times as the action allows if it fails, and returns the event
+// telling how it went.
+func (r *Run) runAction(ctx context.Context, i int, action config.Action) Event {
+	onLine := r.lineHandler(i)
+
+	delay := retryDelay
+	for attempt := 1; ; attempt++ {
+		ev := r.attempt(ctx, i, action, onLine)
+		if ev.Status != StatusFailed || attempt > action.Retries {
+			return ev
+		}
+
+		onLine(fmt.Sprintf("Retrying in %s (attempt %d of %d)", delay, attempt+1, action.Retries+1))
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			ev.Status = StatusInterrupted
+			return ev
+		}
+		delay = min(delay*2, maxRetryDelay)
+	}
+}
+
+// lineHandler returns the function turning the output lines of the
+// scripts of the action at index i into events.
+func (r *Run) lineHandler(i int) func(string) {
+	return func(line string) {
+		ev, ok := parseControl(line)
+		if !ok {
+			ev = Event{Kind: ActionOutput, Text: line}
+		}
+		ev.Index = i
+		r.emit(ev)
+	}
+}
+
+// attempt executes the script of action, at index i, once.
+func (r *Run) attempt(ctx context.Context, i int, action config.Action, onLine func(string)) Event {
+	scriptCtx := ctx
+	if action.Timeout > 0 {
+		var cancel context.CancelFunc
+		scriptCtx, cancel = context.WithTimeout(ctx, action.Timeout)
+		defer cancel()
+	}
+
+	if err := r.resetOutput(i); err != nil {
+		log.Warnf("Run %s: failed to create output file: %v", r.ID, err)
+	}
+
+	var code int
+	var err error
+	switch {
+	case action.Interactive && action.AsRoot() && !r.root:
+		code, err = -1, ErrInteractivePrivileged
+	case action.Interactive:
+		code, err = runTerminal(scriptCtx, action.Script, r.options(i), func(t *Terminal) {
+			r.openTerminal(i, t)
+		}, onLine)
+	case action.AsRoot() && !r.root:
+		code, err = r.runPrivileged(scriptCtx, i, action, onLine)
+	default:
+		code, err = runScript(scriptCtx, action.Script, r.options(i), onLine)
+	}
+
+	ev := Event{Kind: ActionFinished, Index: i, ExitCode: code, Status: StatusFailed}
+	if err != nil {
+		ev.Text = err.Error()
+	}
+	switch {
+	case ctx.Err() != nil:
+		ev.Status = StatusInterrupted
+	case scriptCtx.Err() != nil:
+		ev.Text = fmt.Sprintf("timed out after %s", action.Timeout)
+	case err != nil:
+	case action.Skipped(code):
+		ev.Status = StatusSkipped
+	case action.Succeeded(code):
+		ev.Status = StatusSuccess
+	}
+	return ev
+}
+
+// runPrivileged executes the script of action, at index i, as root,
+// through the privileged helper of the run.
+func (r *Run) runPrivileged(ctx context.Context, i int, action config.Action, onLine func(string)) (int, error) {
+	// Don't ask again for every action if the user refused to authenticate
+	if r.helper == nil && r.helperErr == nil {
+		h, err := startHelper(ctx, r.helperCmd)
+		if err != nil && ctx.Err() != nil {
+			// Interrupted or timed out, the next action can ask again
+			return -1, err
+		}
+		r.helper, r.helperErr = h, err
+	}
+	if r.helperErr != nil {
+		return -1, r.helperErr
+	}
+	return r.helper.run(ctx, action, r.env(i), onLine)
+}
+
+// applied runs the check script of action, if any, and reports whether
+// it found the action already applied. Its output is discarded.
+func applied(ctx context.Context, action config.Action, opts scriptOptions) bool {
+	if action.Check == "" {
+		return false
+	}
+	code, err := runScript(ctx, action.Check, opts, func(string) {})
+	return err == nil && code == 0
+}
+
+// record saves the outcome of action in the state file, if any.
+func (r *Run) record(action config.Action, ev Event) {
+	if r.state == nil {
+		return
+	}
+	result := ev.Status
+	hash := action.Hash()
+	if r.removal[ev.Index] {
+		if result == StatusSuccess {
+			result = StatusReverted
+		}
+		// action runs the removal script, its hash is not the one of the
+		// action that was applied
+		hash = ""
+	}
+	err := r.state.RecordAction(action.ID, state.ActionState{
+		LastRun:    time.Now(),
+		Result:     string(result),
+		ExitCode:   ev.ExitCode,
+		ConfigHash: hash,
+	})
+	if err != nil {
+		log.Errorf("Failed to record state of action %q: %v", action.ID, err)
+	}
+}
+
+// options returns how the scripts of the action at index i are run, with
+// the standard environment.
+func (r *Run) options(i int) scriptOptions {
+	opts := optionsFor(r.Actions[i], r.grace, r.root, r.user)
+	opts.env = append(r.env(i), opts.env...)
+	return opts
+}
+
+// optionsFor returns how the scripts of action are run: when yafti runs
+// as root, scripts not meant for root run as the session user.
+func optionsFor(action config.Action, grace time.Duration, root bool, user *session.User) scriptOptions {
+	opts := scriptOptions{
+		grace: grace,
+		shell: action.Interpreter(),
+		dir:   action.Workdir,
+	}
+	for _, k := range slices.Sorted(maps.Keys(action.Env)) {
+		opts.env = append(opts.env, k+"="+action.Env[k])
+	}
+	if root && !action.AsRoot() {
+		opts.user = user
+	}
+	return opts
+}
+
+// scriptOptions tune how [runScript] runs a script.
+type scriptOptions struct {
+	// Time the script gets to exit once interrupted, before it is killed
+	grace time.Duration
+	// Interpreter running the script with -c
+	shell string
+	// Working directory, if set
+	dir string
+	// Environment variables added to the inherited ones, as "key=value"
+	env []string
+	// If set, run the script as this user, in their session environment
+	user *session.User
+}
+
+// runScript executes script with its interpreter, calling onLine for every line
+// of combined stdout and stderr. It returns the exit code of the script,
+// and an error if it could not be run or was interrupted.
+func runScript(ctx context.Context, script string, opts scriptOptions, onLine func(string)) (int, error) {
+	pr, pw := io.Pipe()
+
+	com, stopKill := scriptCommand(ctx, script, opts)
+	com.Stdout = pw
+	com.Stderr = pw
+	// Run in its own process group, so children of the script are
+	// signaled along with it.
+	com.SysProcAttr.Setpgid = true
+	// Don't wait forever on background processes keeping the output open
+	com.WaitDelay = opts.grace + time.Second
+
+	if err := com.Start(); err != nil {
+		pw.Close()
+		return -1, err
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		rd := bufio.NewReader(pr)
+		for {
+			line, err := rd.ReadString('\n')
+			if line != "" {
+				onLine(strings.TrimRight(line, "\r\n"))
+			}
+			if err != nil {
+				if err != io.EOF {
+					onLine("Error: " + err.Error())
+				}
+				return
+			}
+		}
+	}()
+
+	err := com.Wait()
+	stopKill()
+	pw.Close()
+	wg.Wait()
+
+	return exitCode(ctx, err)
+}
+
+// scriptCommand returns the command executing script with its
+// interpreter, as set by opts. Its process group must be the one of the
+// script, as it is what gets signaled.
+//
+// When ctx is cancelled, the process group of the script gets SIGTERM,
+// and SIGKILL if it is still around after the grace period, unless
+// stopKill was called in the meantime.
+func scriptCommand(ctx context.Context, script string, opts scriptOptions) (com *exec.Cmd, stopKill func()) {
+	script = strings.Trim(script, "\n\r\t")
+
+	shell := opts.shell
+	if shell == "" {
+		shell = config.DefaultShell
+	}
+	com = exec.CommandContext(ctx, shell, "-c", script)
+
+	var mu sync.Mutex
+	var killTimer *time.Timer
+	com.SysProcAttr = &syscall.SysProcAttr{}
+	com.Env = os.Environ()
+	if opts.user != nil {
+		com.SysProcAttr.Credential = opts.user.Credential()
+		com.Env = opts.user.Env(com.Env)
+		com.Dir = opts.user.Home
+	}
+	com.Env = append(com.Env, opts.env...)
+	if opts.dir != "" {
+		com.Dir = opts.dir
+	}
+	com.Cancel = func() error {
+		pgid := com.Process.Pid
+		mu.Lock()
+		killTimer = time.AfterFunc(opts.grace, func() {
+			syscall.Kill(-pgid, syscall.SIGKILL)
+		})
+		mu.Unlock()
+		return syscall.Kill(-pgid, syscall.SIGTERM)
+	}
+
+	return com, func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if killTimer != nil {
+			killTimer.Stop()
+		}
+	}
+}
+
+// exitCode returns what [runScript] returns for a script that exited
+// with err, as returned by [exec.Cmd.Wait].
+func exitCode(ctx context.Context, err error) (int, error) {
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return 0, nil
+	case ctx.Err() != nil:
+		return -1, ctx.Err()
+	case errors.As(err, &exitErr):
+		return exitErr.ExitCode(), nil
+	default:
+		return -1, err
+	}
+}
@@ -0,0 +1,232 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/creack/pty"
+)
+
+// Scripts of interactive actions run in a pseudo-terminal instead of
+// pipes, so they can ask questions. Frontends show it and forward what the
+// user types, see [Run.Terminal].
+
+// ErrInteractivePrivileged is returned when an interactive action must run
+// as root through the privileged helper, which has no terminal to offer.
+var ErrInteractivePrivileged = errors.New("interactive actions can only run as root when yafti itself runs as root")
+
+// Size of a terminal until a frontend tells its own.
+const (
+	defaultTermCols = 80
+	defaultTermRows = 24
+)
+
+// Terminal is the pseudo-terminal the script of an interactive action runs
+// in. Everything the script writes to it is kept, so every frontend showing
+// it can replay it from the start.
+type Terminal struct {
+	pty *os.File
+
+	mu      sync.Mutex
+	output  []byte
+	closed  bool
+	changed chan struct{} // Closed and replaced every time output is added
+	done    chan struct{}
+}
+
+func newTerminal(f *os.File) *Terminal {
+	return &Terminal{
+		pty:     f,
+		changed: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// Write sends what the user typed to the script.
+func (t *Terminal) Write(p []byte) (int, error) {
+	return t.pty.Write(p)
+}
+
+// Resize changes the size of the terminal, in characters.
+func (t *Terminal) Resize(cols, rows int) error {
+	if cols <= 0 || rows <= 0 || cols > 0xffff || rows > 0xffff {
+		return errors.New("invalid terminal size")
+	}
+	return pty.Setsize(t.pty, &pty.Winsize{Cols: uint16(cols), Rows: uint16(rows)})
+}
+
+// Done is closed once the script exited and its output was read.
+func (t *Terminal) Done() <-chan struct{} {
+	return t.done
+}
+
+// Output replays what the script wrote so far, then follows what it
+// writes until it exits or ctx is cancelled.
+func (t *Terminal) Output(ctx context.Context) <-chan []byte {
+	out := make(chan []byte)
+
+	go func() {
+		defer close(out)
+
+		next := 0
+		for {
+			t.mu.Lock()
+			pending := t.output[next:]
+			changed := t.changed
+			closed := t.closed
+			t.mu.Unlock()
+
+			if len(pending) > 0 {
+				select {
+				case out <- pending:
+				case <-ctx.Done():
+					return
+				}
+				next += len(pending)
+				continue
+			}
+			if closed {
+				return
+			}
+
+			select {
+			case <-changed:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+func (t *Terminal) append(p []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.output = append(t.output, p...)
+	close(t.changed)
+	t.changed = make(chan struct{})
+}
+
+func (t *Terminal) close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closed = true
+	close(t.changed)
+	close(t.done)
+}
+
+// Terminal returns the terminal number n of the run, as told by a
+// [TerminalOpened] event.
+func (r *Run) Terminal(n int) (*Terminal, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if n < 0 || n >= len(r.terminals) {
+		return nil, false
+	}
+	return r.terminals[n], true
+}
+
+// openTerminal tells the frontends the script of the action at index i
+// runs in t.
+func (r *Run) openTerminal(i int, t *Terminal) {
+	r.mu.Lock()
+	n := len(r.terminals)
+	r.terminals = append(r.terminals, t)
+	r.mu.Unlock()
+
+	r.emit(Event{Kind: TerminalOpened, Index: i, Terminal: n})
+}
+
+// runTerminal executes script like [runScript], in a pseudo-terminal given
+// to onOpen once the script started. onLine still gets every line of
+// output, without the terminal escape sequences.
+func runTerminal(ctx context.Context, script string, opts scriptOptions, onOpen func(*Terminal), onLine func(string)) (int, error) {
+	com, stopKill := scriptCommand(ctx, script, opts)
+	// The script gets its own session, which makes it the leader of its
+	// process group as well
+	f, err := pty.StartWithSize(com, &pty.Winsize{Cols: defaultTermCols, Rows: defaultTermRows})
+	if err != nil {
+		return -1, err
+	}
+
+	t := newTerminal(f)
+	onOpen(t)
+
+	read := make(chan struct{})
+	go func() {
+		defer close(read)
+
+		lines := &lineWriter{onLine: onLine}
+		buf := make([]byte, 4096)
+		for {
+			n, err := f.Read(buf)
+			if n > 0 {
+				t.append(buf[:n])
+				lines.Write(buf[:n])
+			}
+			if err != nil {
+				// Linux reports EIO once the script and its children are gone
+				lines.flush()
+				return
+			}
+		}
+	}()
+
+	err = com.Wait()
+	stopKill()
+	// Don't wait forever on background processes keeping the terminal open
+	select {
+	case <-read:
+	case <-time.After(opts.grace + time.Second):
+	}
+	f.Close()
+	<-read
+	t.close()
+
+	return exitCode(ctx, err)
+}
+
+// Escape sequences and control characters of terminal output, which don't
+// belong in plain text lines
+var escapeSeq = regexp.MustCompile(`\x1b\[[0-?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)?|\x1b[ -/]*[0-~]|[\x00-\x08\x0b-\x1f\x7f]`)
+
+// lineWriter splits terminal output into plain text lines.
+type lineWriter struct {
+	onLine func(string)
+	buf    []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		w.emit(w.buf[:i])
+		w.buf = w.buf[i+1:]
+	}
+}
+
+func (w *lineWriter) flush() {
+	if len(w.buf) > 0 {
+		w.emit(w.buf)
+		w.buf = nil
+	}
+}
+
+func (w *lineWriter) emit(line []byte) {
+	s := strings.TrimRight(string(line), "\r")
+	// What was overwritten by returning to the start of the line is gone
+	if i := strings.LastIndexByte(s, '\r'); i >= 0 {
+		s = s[i+1:]
+	}
+	w.onLine(escapeSeq.ReplaceAllString(s, ""))
+}
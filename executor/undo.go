@@ -0,0 +1,80 @@
+package executor
+
+import (
+	"cmp"
+	"context"
+	"slices"
+
+	"github.com/Zeglius/yafti-go/config"
+)
+
+// Actions can have an undo script, reverting what their script did. It
+// runs when the script fails, according to the rollback_on_failure of the
+// action, or when the user reverts a past action, see [Executor.Revert].
+
+// undo runs the undo script of the action at index i, if any.
+func (r *Run) undo(ctx context.Context, i int) {
+	action := r.Actions[i]
+	if action.Undo == "" || ctx.Err() != nil {
+		return
+	}
+
+	r.emit(Event{Kind: UndoStarted, Index: i})
+	ev := r.attempt(ctx, i, action.UndoAction(), r.lineHandler(i))
+	ev.Kind = UndoFinished
+	if ev.Status == StatusSuccess {
+		ev.Status = StatusReverted
+		r.record(action, ev)
+	}
+	r.emit(ev)
+}
+
+// rollBack reverts the actions that completed, the last one first.
+func (r *Run) rollBack(ctx context.Context) {
+	results := r.Results()
+	var done []int
+	for i, res := range results {
+		if res.Status == StatusSuccess {
+			done = append(done, i)
+		}
+	}
+	slices.SortFunc(done, func(a, b int) int {
+		return cmp.Compare(results[b].Finished.UnixNano(), results[a].Finished.UnixNano())
+	})
+
+	for _, i := range done {
+		r.undo(ctx, i)
+	}
+}
+
+// onFailed applies the rollback_on_failure of the action at index i,
+// once its script failed for good.
+func (r *Run) onFailed(ctx context.Context, i int) {
+	switch r.Actions[i].RollbackOnFailure {
+	case config.RollbackAction:
+		r.undo(ctx, i)
+	case config.RollbackRun:
+		r.undo(ctx, i)
+		r.mu.Lock()
+		r.rollback = true
+		r.mu.Unlock()
+		r.stop()
+	}
+}
+
+// Revert starts a new run executing the undo scripts of actions, which
+// are recorded as reverted once they succeed. Actions without an undo
+// script are left out.
+func (x *Executor) Revert(actions []config.Action) (*Run, error) {
+	var undo []config.Action
+	for _, act := range actions {
+		if act.Undo != "" {
+			undo = append(undo, act.UndoAction())
+		}
+	}
+	removal := make([]bool, len(undo))
+	for i := range removal {
+		removal[i] = true
+	}
+	return x.start(undo, removal)
+}
@@ -19,9 +19,10 @@ require (
 	github.com/labstack/echo/v4 v4.13.3
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
-	golang.org/x/net v0.37.0 // indirect
+	golang.org/x/net v0.37.0
 	golang.org/x/sync v0.13.0
-	golang.org/x/sys v0.31.0 // indirect
+	golang.org/x/sys v0.31.0
+	golang.org/x/term v0.30.0
 )
 
 require (
@@ -34,4 +35,8 @@ require (
 	golang.org/x/time v0.8.0 // indirect
 )
 
+require github.com/creack/pty v1.1.24
+
+require github.com/godbus/dbus/v5 v5.1.0
+
 tool github.com/a-h/templ/cmd/templ
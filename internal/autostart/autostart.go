@@ -0,0 +1,71 @@
+// Package autostart manages XDG autostart entries, which the desktop
+// session launches on login.
+package autostart
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func dir() (string, error) {
+	base := os.Getenv("XDG_CONFIG_HOME")
+	if base == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		base = filepath.Join(home, ".config")
+	}
+	return filepath.Join(base, "autostart"), nil
+}
+
+// Install writes the autostart entry name, running the command line exec.
+// Arguments of exec must be quoted with [Quote].
+func Install(name, comment, exec string) error {
+	d, err := dir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(d, 0o755); err != nil {
+		return err
+	}
+
+	entry := fmt.Sprintf(`[Desktop Entry]
+Type=Application
+Name=Yafti
+Comment=%s
+Exec=%s
+Icon=yafti
+NoDisplay=true
+X-GNOME-Autostart-enabled=true
+`, comment, exec)
+
+	return os.WriteFile(filepath.Join(d, name+".desktop"), []byte(entry), 0o644)
+}
+
+// Remove deletes the autostart entry name, if it exists.
+func Remove(name string) error {
+	d, err := dir()
+	if err != nil {
+		return err
+	}
+	err = os.Remove(filepath.Join(d, name+".desktop"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Quote escapes an argument for the Exec key of a desktop entry.
+func Quote(arg string) string {
+	// Field codes start with %, so a literal one must be doubled
+	arg = strings.ReplaceAll(arg, "%", "%%")
+	if arg != "" && !strings.ContainsAny(arg, " \t\n\"'\\><~|&;$*?#()`") {
+		return arg
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "`", "\\`", `$`, `\$`)
+	return `"` + r.Replace(arg) + `"`
+}
@@ -1,11 +1,14 @@
 package consts
 
+import "time"
+
 const (
-	PORT           = "3169"     // Port to run the server on
-	HTML_TMPL_PATH = "html-src" // Where we store our HTML templates
-	STATIC_PATH    = "static"   // Where we store our static files (js, images, etc.)
+	HOST           = "127.0.0.1" // Address to run the server on
+	PORT           = "3169"      // Port to run the server on
+	HTML_TMPL_PATH = "html-src"  // Where we store our HTML templates
+	STATIC_PATH    = "static"    // Where we store our static files (js, images, etc.)
 	APP_TITLE      = "Yafti"
-	// Time limit (in seconds) to check if a client is connected.
-	// If it is not, shutdown the server.
-	HEARTBEAT_SECONDS = 30
+	// Default time to wait without any client connected before
+	// shutting down the server.
+	IDLE_TIMEOUT = 30 * time.Second
 )
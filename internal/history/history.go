@@ -0,0 +1,233 @@
+// Package history keeps the record of past runs, with the full output of
+// every action, so it can be looked at after the run page is closed.
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Zeglius/yafti-go/config"
+	"github.com/Zeglius/yafti-go/internal/state"
+	"github.com/Zeglius/yafti-go/internal/xdg"
+)
+
+// Number of runs kept when [Store.Limit] is not set
+const DefaultLimit = 50
+
+// ActionRecord is the outcome of a single action of a run.
+type ActionRecord struct {
+	Action   config.Action `json:"action"`
+	Status   string        `json:"status"`
+	ExitCode int           `json:"exit_code"`
+	Error    string        `json:"error,omitempty"`
+	Started  time.Time     `json:"started,omitzero"`
+	Finished time.Time     `json:"finished,omitzero"`
+	Output   []string      `json:"output"`
+	// Whether the action ran the uninstall or undo script of the action
+	// with the same ID, to remove it
+	Removal bool `json:"removal,omitempty"`
+	// Reported by the script with control lines
+	Warnings []string `json:"warnings,omitempty"`
+	Links    []Link   `json:"links,omitempty"`
+}
+
+// Link is a web page a script pointed the user to, e.g. the UI of the
+// service it installed.
+type Link struct {
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+// Succeeded reports whether the action ran successfully, or was skipped
+// as already applied.
+func (a ActionRecord) Succeeded() bool {
+	return a.Status == state.ResultSuccess || a.Status == state.ResultSkipped
+}
+
+// Revertable reports whether the action completed and has an undo script.
+func (a ActionRecord) Revertable() bool {
+	return a.Status == state.ResultSuccess && a.Action.Undo != ""
+}
+
+// Record is a finished run.
+type Record struct {
+	ID       string         `json:"id"`
+	Started  time.Time      `json:"started"`
+	Finished time.Time      `json:"finished"`
+	Actions  []ActionRecord `json:"actions"`
+}
+
+// Succeeded reports whether every action of the run succeeded.
+func (r Record) Succeeded() bool {
+	return !slices.ContainsFunc(r.Actions, func(a ActionRecord) bool {
+		return !a.Succeeded()
+	})
+}
+
+// Failed returns the actions that did not succeed, including the ones
+// that never ran, and which ones are removals. Reverted actions are left
+// out, they were undone on purpose.
+func (r Record) Failed() (actions []config.Action, removal []bool) {
+	for _, a := range r.Actions {
+		if !a.Succeeded() && a.Status != state.ResultReverted {
+			actions = append(actions, a.Action)
+			removal = append(removal, a.Removal)
+		}
+	}
+	return actions, removal
+}
+
+// Retryable reports whether the run has actions to run again, see
+// [Record.Failed].
+func (r Record) Retryable() bool {
+	actions, _ := r.Failed()
+	return len(actions) > 0
+}
+
+// WriteTranscript writes the run as plain text, for reading or sharing.
+func (r Record) WriteTranscript(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "yafti run %s\n", r.ID)
+	fmt.Fprintf(&b, "Started:  %s\n", r.Started.Format(time.RFC1123))
+	fmt.Fprintf(&b, "Finished: %s\n", r.Finished.Format(time.RFC1123))
+
+	for _, a := range r.Actions {
+		fmt.Fprintf(&b, "\n==> %s (%s): %s", a.Action.Title, a.Action.ID, a.Status)
+		if !a.Started.IsZero() {
+			fmt.Fprintf(&b, ", exit code %d, took %s", a.ExitCode, a.Finished.Sub(a.Started).Round(time.Millisecond))
+		}
+		b.WriteString("\n")
+		if a.Error != "" {
+			fmt.Fprintf(&b, "Error: %s\n", a.Error)
+		}
+		fmt.Fprintf(&b, "$ %s\n", strings.Trim(a.Action.Script, "\n\r\t"))
+		for _, line := range a.Output {
+			b.WriteString(line + "\n")
+		}
+		for _, w := range a.Warnings {
+			fmt.Fprintf(&b, "Warning: %s\n", w)
+		}
+		for _, l := range a.Links {
+			fmt.Fprintf(&b, "Link: %s %s\n", l.Title, l.URL)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Store is the directory holding the records of past runs.
+type Store struct {
+	dir string
+	// Number of runs kept, older ones are removed. [DefaultLimit] if zero.
+	Limit int
+}
+
+// Open returns the history directory under $XDG_STATE_HOME.
+func Open() (*Store, error) {
+	dir, err := xdg.StateDir()
+	if err != nil {
+		return nil, err
+	}
+	dir = filepath.Join(dir, "history")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &Store{dir: dir}, nil
+}
+
+func (s *Store) path(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+// Save stores the record of a run, then removes the oldest ones past
+// the limit.
+func (s *Store) Save(rec Record) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	tmp := s.path(rec.ID) + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path(rec.ID)); err != nil {
+		return err
+	}
+	return s.prune()
+}
+
+// ids returns the IDs of the stored runs, oldest first. Run IDs start
+// with their start time, so they sort chronologically.
+func (s *Store) ids() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, e := range entries {
+		if id, ok := strings.CutSuffix(e.Name(), ".json"); ok {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
+
+func (s *Store) prune() error {
+	limit := s.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	ids, err := s.ids()
+	if err != nil {
+		return err
+	}
+	for len(ids) > limit {
+		if err := os.Remove(s.path(ids[0])); err != nil {
+			return err
+		}
+		ids = ids[1:]
+	}
+	return nil
+}
+
+// List returns the stored runs, newest first.
+func (s *Store) List() ([]Record, error) {
+	ids, err := s.ids()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]Record, 0, len(ids))
+	for _, id := range slices.Backward(ids) {
+		rec, err := s.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, rec)
+	}
+	return res, nil
+}
+
+// Get returns the stored run with the given ID. The error satisfies
+// errors.Is(err, os.ErrNotExist) if there is none.
+func (s *Store) Get(id string) (Record, error) {
+	var rec Record
+	if id == "" || filepath.Base(id) != id || strings.HasPrefix(id, ".") {
+		return rec, os.ErrNotExist
+	}
+	b, err := os.ReadFile(s.path(id))
+	if err != nil {
+		return rec, err
+	}
+	if err := json.Unmarshal(b, &rec); err != nil {
+		return rec, fmt.Errorf("run %s: %w", id, err)
+	}
+	return rec, nil
+}
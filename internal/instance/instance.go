@@ -0,0 +1,108 @@
+// Package instance makes sure only one yafti server runs per user.
+//
+// The running instance holds a lock on a file in the runtime directory,
+// and publishes its URL in it, so later launches can hand off to it.
+package instance
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/Zeglius/yafti-go/internal/xdg"
+)
+
+// ErrRunning is returned by [Acquire] when another instance holds the lock.
+var ErrRunning = errors.New("another yafti instance is running")
+
+// Info is what the running instance publishes about itself.
+type Info struct {
+	PID int    `json:"pid"`
+	URL string `json:"url"`
+}
+
+// Lock is held by the running instance until [Lock.Release] is called or
+// the process exits.
+type Lock struct {
+	f *os.File
+}
+
+func lockPath() (string, error) {
+	dir, err := xdg.RuntimeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "instance.lock"), nil
+}
+
+// Acquire takes the per-user instance lock. If another instance holds it,
+// it returns [ErrRunning].
+func Acquire() (*Lock, error) {
+	path, err := lockPath()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, ErrRunning
+		}
+		return nil, err
+	}
+
+	return &Lock{f: f}, nil
+}
+
+// Publish records the URL of this instance for later launches to find.
+func (l *Lock) Publish(url string) error {
+	data, err := json.Marshal(Info{PID: os.Getpid(), URL: url})
+	if err != nil {
+		return err
+	}
+	if err := l.f.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := l.f.WriteAt(data, 0); err != nil {
+		return err
+	}
+	return l.f.Sync()
+}
+
+// Release gives up the lock, letting another instance start.
+func (l *Lock) Release() error {
+	l.f.Truncate(0)
+	return l.f.Close()
+}
+
+// Running returns what the running instance published about itself.
+// The URL is empty if the instance has not started listening yet.
+func Running() (Info, error) {
+	var info Info
+
+	path, err := lockPath()
+	if err != nil {
+		return info, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return info, err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil || len(data) == 0 {
+		return info, err
+	}
+	err = json.Unmarshal(data, &info)
+	return info, err
+}
@@ -0,0 +1,269 @@
+// Package journal keeps a write-ahead log of every run, so runs cut short
+// by a crash or a reboot can be resumed on the next launch.
+//
+// Each run gets a JSON lines file, removed once the run finishes. Any
+// file left behind is a run to resume or discard.
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Zeglius/yafti-go/config"
+	"github.com/Zeglius/yafti-go/internal/autostart"
+	"github.com/Zeglius/yafti-go/internal/xdg"
+)
+
+// Name of the autostart entry installed while a run waits for a reboot
+const autostartName = "yafti-resume"
+
+type recordType string
+
+const (
+	recRunStarted     recordType = "run_started"
+	recActionStarted  recordType = "action_started"
+	recActionFinished recordType = "action_finished"
+	recRebootRequired recordType = "reboot_required"
+)
+
+type record struct {
+	Type    recordType      `json:"type"`
+	Time    time.Time       `json:"time"`
+	Actions []config.Action `json:"actions,omitempty"` // For run_started
+	Action  string          `json:"action,omitempty"`
+	Status  string          `json:"status,omitempty"` // For action_finished
+	Code    int             `json:"exit_code,omitempty"`
+}
+
+// Journal is the directory holding the journals of unfinished runs.
+type Journal struct {
+	dir string
+	mu  sync.Mutex
+	// Command line relaunching yafti to resume, written to an autostart
+	// entry while a run waits for a reboot. Nothing is autostarted if empty.
+	ResumeExec string
+}
+
+// Open returns the journal directory under $XDG_STATE_HOME.
+func Open() (*Journal, error) {
+	dir, err := xdg.StateDir()
+	if err != nil {
+		return nil, err
+	}
+	dir = filepath.Join(dir, "journal")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &Journal{dir: dir}, nil
+}
+
+func (j *Journal) path(runID string) string {
+	return filepath.Join(j.dir, runID+".jsonl")
+}
+
+// Writer appends the records of a single run. Methods of a nil Writer do
+// nothing, for runs without a journal.
+type Writer struct {
+	j     *Journal
+	runID string
+	mu    sync.Mutex
+	f     *os.File
+}
+
+// Begin starts the journal of a run, recording the actions it will execute.
+func (j *Journal) Begin(runID string, actions []config.Action) (*Writer, error) {
+	f, err := os.OpenFile(j.path(runID), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	w := &Writer{j: j, runID: runID, f: f}
+	return w, w.write(record{Type: recRunStarted, Actions: actions})
+}
+
+// write appends rec and flushes it to disk before returning.
+func (w *Writer) write(rec record) error {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.f == nil {
+		return errors.New("journal already closed")
+	}
+	rec.Time = time.Now()
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if _, err := w.f.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	return w.f.Sync()
+}
+
+// Close stops writing to the journal, keeping it so the run can be resumed.
+func (w *Writer) Close() error {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f == nil {
+		return nil
+	}
+	err := w.f.Close()
+	w.f = nil
+	return err
+}
+
+func (w *Writer) ActionStarted(id string) error {
+	return w.write(record{Type: recActionStarted, Action: id})
+}
+
+// ActionFinished records that action id is done, and must not run again
+// on resume. Interrupted actions should not be recorded.
+func (w *Writer) ActionFinished(id, status string, exitCode int) error {
+	return w.write(record{Type: recActionFinished, Action: id, Status: status, Code: exitCode})
+}
+
+// RebootRequired records that the run stopped after action id to let the
+// system reboot. The journal is kept, and yafti is set to autostart on the
+// next login to resume the run.
+func (w *Writer) RebootRequired(id string) error {
+	if w == nil {
+		return nil
+	}
+	if err := w.write(record{Type: recRebootRequired, Action: id}); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return w.j.syncAutostart()
+}
+
+// Finish marks the run as finished, removing its journal.
+func (w *Writer) Finish() error {
+	if w == nil {
+		return nil
+	}
+	w.Close()
+	return w.j.Discard(w.runID)
+}
+
+// Pending is a run that did not finish.
+type Pending struct {
+	RunID   string
+	Started time.Time
+	// Actions that did not finish, in order
+	Remaining []config.Action
+	// Set if the run stopped on purpose to reboot after this action
+	RebootAfter string
+}
+
+// Pending returns the runs that did not finish, oldest first.
+func (j *Journal) Pending() ([]Pending, error) {
+	entries, err := os.ReadDir(j.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []Pending
+	for _, e := range entries {
+		runID, ok := strings.CutSuffix(e.Name(), ".jsonl")
+		if !ok {
+			continue
+		}
+		p, err := j.read(runID)
+		if err != nil {
+			return nil, err
+		}
+		if len(p.Remaining) > 0 {
+			res = append(res, p)
+		}
+	}
+
+	slices.SortFunc(res, func(a, b Pending) int {
+		return a.Started.Compare(b.Started)
+	})
+	return res, nil
+}
+
+// read replays the journal of a run to find what is left to do.
+// A partially written last line (from a crash) is ignored.
+func (j *Journal) read(runID string) (Pending, error) {
+	p := Pending{RunID: runID}
+
+	f, err := os.Open(j.path(runID))
+	if err != nil {
+		return p, err
+	}
+	defer f.Close()
+
+	finished := make(map[string]bool)
+	var actions []config.Action
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 1<<24)
+	for sc.Scan() {
+		var rec record
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			continue
+		}
+		switch rec.Type {
+		case recRunStarted:
+			p.Started = rec.Time
+			actions = rec.Actions
+		case recActionFinished:
+			finished[rec.Action] = true
+		case recRebootRequired:
+			p.RebootAfter = rec.Action
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return p, err
+	}
+
+	for _, act := range actions {
+		if !finished[act.ID] {
+			p.Remaining = append(p.Remaining, act)
+		}
+	}
+	return p, nil
+}
+
+// Discard forgets about an unfinished run.
+func (j *Journal) Discard(runID string) error {
+	if err := os.Remove(j.path(runID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return j.syncAutostart()
+}
+
+// syncAutostart installs the autostart entry while some run waits for a
+// reboot, and removes it otherwise.
+func (j *Journal) syncAutostart() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	pending, err := j.Pending()
+	if err != nil {
+		return err
+	}
+
+	waiting := slices.ContainsFunc(pending, func(p Pending) bool {
+		return p.RebootAfter != ""
+	})
+	if waiting && j.ResumeExec != "" {
+		return autostart.Install(autostartName, "Resume yafti installation", j.ResumeExec)
+	}
+	return autostart.Remove(autostartName)
+}
@@ -0,0 +1,111 @@
+package journal
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/Zeglius/yafti-go/config"
+)
+
+func TestPending(t *testing.T) {
+	actions := []config.Action{
+		{ID: "a", Title: "A", Script: "true"},
+		{ID: "b", Title: "B", Script: "true"},
+		{ID: "c", Title: "C", Script: "true"},
+	}
+
+	for _, tt := range []struct {
+		name          string
+		write         func(t *testing.T, w *Writer, path string)
+		wantRemaining []string // IDs, no pending run if empty
+		wantReboot    string
+	}{
+		{"finished", func(t *testing.T, w *Writer, path string) {
+			for _, act := range actions {
+				w.ActionStarted(act.ID)
+				w.ActionFinished(act.ID, "success", 0)
+			}
+			w.Finish()
+		}, nil, ""},
+		{"crashed", func(t *testing.T, w *Writer, path string) {
+			w.ActionStarted("a")
+			w.ActionFinished("a", "success", 0)
+			// Interrupted, so not finished
+			w.ActionStarted("b")
+			w.Close()
+		}, []string{"b", "c"}, ""},
+		{"failed actions are not run again", func(t *testing.T, w *Writer, path string) {
+			w.ActionStarted("a")
+			w.ActionFinished("a", "failed", 1)
+			w.Close()
+		}, []string{"b", "c"}, ""},
+		{"waiting for a reboot", func(t *testing.T, w *Writer, path string) {
+			w.ActionStarted("a")
+			w.ActionFinished("a", "success", 0)
+			w.RebootRequired("a")
+		}, []string{"b", "c"}, "a"},
+		{"partially written last line", func(t *testing.T, w *Writer, path string) {
+			w.ActionStarted("a")
+			w.ActionFinished("a", "success", 0)
+			w.Close()
+			f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.WriteString(`{"type":"action_finished","action":"b","sta`)
+			f.Close()
+		}, []string{"b", "c"}, ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+			j := &Journal{dir: t.TempDir(), ResumeExec: "yafti serve --resume"}
+			w, err := j.Begin("run", actions)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.write(t, w, j.path("run"))
+
+			pending, err := j.Pending()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(tt.wantRemaining) == 0 {
+				if len(pending) != 0 {
+					t.Errorf("got pending runs %+v, want none", pending)
+				}
+				return
+			}
+			if len(pending) != 1 {
+				t.Fatalf("got %d pending runs, want 1", len(pending))
+			}
+
+			p := pending[0]
+			var ids []string
+			for _, act := range p.Remaining {
+				ids = append(ids, act.ID)
+			}
+			if !slices.Equal(ids, tt.wantRemaining) || p.RebootAfter != tt.wantReboot {
+				t.Errorf("got remaining %q and reboot after %q, want %q and %q", ids, p.RebootAfter, tt.wantRemaining, tt.wantReboot)
+			}
+
+			// Only a run waiting for a reboot is resumed automatically
+			_, err = os.Stat(filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "autostart", autostartName+".desktop"))
+			if autostarted := err == nil; autostarted != (tt.wantReboot != "") {
+				t.Errorf("autostart entry installed: %v", autostarted)
+			}
+
+			// Discarding the run removes the autostart entry
+			if err := j.Discard(p.RunID); err != nil {
+				t.Fatal(err)
+			}
+			if pending, _ := j.Pending(); len(pending) != 0 {
+				t.Errorf("run still pending once discarded")
+			}
+			if _, err := os.Stat(filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "autostart", autostartName+".desktop")); err == nil {
+				t.Errorf("autostart entry left once the run was discarded")
+			}
+		})
+	}
+}
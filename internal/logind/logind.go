@@ -0,0 +1,45 @@
+// Package logind takes inhibitor locks from systemd-logind, keeping the
+// system from sleeping or shutting down while yafti installs something.
+package logind
+
+import (
+	"os"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	dest = "org.freedesktop.login1"
+	path = "/org/freedesktop/login1"
+)
+
+// What an inhibitor lock can inhibit, among others
+const (
+	Sleep    = "sleep"
+	Idle     = "idle"
+	Shutdown = "shutdown"
+)
+
+// Lock is an inhibitor lock, held until it is released.
+type Lock struct {
+	fd *os.File
+}
+
+// Inhibit takes a lock blocking what, over conn, a connection to the system
+// bus. who and why are shown to the user when they try to do what anyway.
+func Inhibit(conn *dbus.Conn, what []string, who, why string) (*Lock, error) {
+	var fd dbus.UnixFD
+	err := conn.Object(dest, path).
+		Call(dest+".Manager.Inhibit", 0, strings.Join(what, ":"), who, why, "block").
+		Store(&fd)
+	if err != nil {
+		return nil, err
+	}
+	return &Lock{fd: os.NewFile(uintptr(fd), "inhibitor")}, nil
+}
+
+// Release gives the lock back, letting logind do what it inhibited.
+func (l *Lock) Release() error {
+	return l.fd.Close()
+}
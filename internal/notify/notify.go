@@ -0,0 +1,109 @@
+// Package notify sends desktop notifications through the
+// org.freedesktop.Notifications service of the session bus.
+package notify
+
+import (
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	dest = "org.freedesktop.Notifications"
+	path = "/org/freedesktop/Notifications"
+)
+
+// Notification is a single desktop notification.
+type Notification struct {
+	Summary string
+	Body    string
+	// Stays shown until the user dismisses it
+	Urgent bool
+	// ID of a notification sent before, to replace instead of showing
+	// another one
+	Replaces uint32
+	// If set, called when the user clicks the notification
+	OnClick func()
+}
+
+// Notifier sends notifications over a connection to the session bus.
+type Notifier struct {
+	conn *dbus.Conn
+	// Name of the desktop entry of the application, without .desktop.
+	// Notification servers use its name and icon.
+	DesktopEntry string
+
+	mu      sync.Mutex
+	onClick map[uint32]func() // By ID of notification still shown
+}
+
+// New returns a notifier sending notifications over conn, a connection to
+// the session bus.
+func New(conn *dbus.Conn) (*Notifier, error) {
+	err := conn.AddMatchSignal(dbus.WithMatchObjectPath(path), dbus.WithMatchInterface(dest))
+	if err != nil {
+		return nil, err
+	}
+
+	n := &Notifier{conn: conn, onClick: make(map[uint32]func())}
+	signals := make(chan *dbus.Signal, 16)
+	conn.Signal(signals)
+	go n.watch(signals)
+	return n, nil
+}
+
+// Send shows msg, and returns its ID.
+func (n *Notifier) Send(msg Notification) (uint32, error) {
+	var actions []string
+	if msg.OnClick != nil {
+		actions = []string{"default", "Open"}
+	}
+	urgency := byte(1)
+	if msg.Urgent {
+		urgency = 2
+	}
+	hints := map[string]dbus.Variant{"urgency": dbus.MakeVariant(urgency)}
+	if n.DesktopEntry != "" {
+		hints["desktop-entry"] = dbus.MakeVariant(n.DesktopEntry)
+	}
+
+	var id uint32
+	err := n.conn.Object(dest, path).
+		Call(dest+".Notify", 0, "yafti", msg.Replaces, "", msg.Summary, msg.Body, actions, hints, int32(-1)).
+		Store(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.onClick, msg.Replaces)
+	if msg.OnClick != nil {
+		n.onClick[id] = msg.OnClick
+	}
+	return id, nil
+}
+
+// watch calls the OnClick of the notifications the user clicks.
+func (n *Notifier) watch(signals <-chan *dbus.Signal) {
+	for sig := range signals {
+		if sig.Path != path || len(sig.Body) < 2 {
+			continue
+		}
+		id, _ := sig.Body[0].(uint32)
+
+		switch sig.Name {
+		case dest + ".ActionInvoked":
+			n.mu.Lock()
+			onClick := n.onClick[id]
+			n.mu.Unlock()
+			if key, _ := sig.Body[1].(string); key == "default" && onClick != nil {
+				onClick()
+			}
+		case dest + ".NotificationClosed":
+			n.mu.Lock()
+			delete(n.onClick, id)
+			n.mu.Unlock()
+		}
+	}
+}
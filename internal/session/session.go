@@ -0,0 +1,119 @@
+// Package session finds the user of the desktop session, for yafti to run
+// their actions as them when it runs as root.
+package session
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// User is a user scripts can be run as.
+type User struct {
+	UID    uint32
+	GID    uint32
+	Groups []uint32
+	Name   string
+	Home   string
+}
+
+// Lookup returns the user with the given name or UID.
+func Lookup(nameOrUID string) (*User, error) {
+	u, err := user.Lookup(nameOrUID)
+	if err != nil {
+		var uerr error
+		if u, uerr = user.LookupId(nameOrUID); uerr != nil {
+			return nil, err
+		}
+	}
+
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return nil, err
+	}
+	gid, err := strconv.ParseUint(u.Gid, 10, 32)
+	if err != nil {
+		return nil, err
+	}
+	res := &User{UID: uint32(uid), GID: uint32(gid), Name: u.Username, Home: u.HomeDir}
+
+	groups, err := u.GroupIds()
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range groups {
+		if id, err := strconv.ParseUint(g, 10, 32); err == nil {
+			res.Groups = append(res.Groups, uint32(id))
+		}
+	}
+	return res, nil
+}
+
+// Active returns the user of the desktop session. It is, in order:
+//   - $YAFTI_USER, a user name or UID
+//   - the user who started yafti with sudo or pkexec
+//   - the user of the active session of seat0, according to logind
+func Active() (*User, error) {
+	for _, key := range []string{"YAFTI_USER", "SUDO_UID", "PKEXEC_UID"} {
+		if v := os.Getenv(key); v != "" {
+			return Lookup(v)
+		}
+	}
+
+	id, err := loginctl("show-seat", "seat0", "--property=ActiveSession", "--value")
+	if err != nil {
+		return nil, err
+	}
+	if id == "" {
+		return nil, errors.New("no active session on seat0, set YAFTI_USER")
+	}
+	uid, err := loginctl("show-session", id, "--property=User", "--value")
+	if err != nil {
+		return nil, err
+	}
+	return Lookup(uid)
+}
+
+func loginctl(args ...string) (string, error) {
+	out, err := exec.Command("loginctl", args...).Output()
+	if err != nil {
+		return "", fmt.Errorf("loginctl %s: %w", strings.Join(args, " "), err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Credential returns the credential to start processes as u.
+func (u *User) Credential() *syscall.Credential {
+	return &syscall.Credential{Uid: u.UID, Gid: u.GID, Groups: u.Groups}
+}
+
+// Env returns base with the variables describing the session of u,
+// replacing the ones already set.
+func (u *User) Env(base []string) []string {
+	runtimeDir := fmt.Sprintf("/run/user/%d", u.UID)
+	vars := [][2]string{
+		{"HOME", u.Home},
+		{"USER", u.Name},
+		{"LOGNAME", u.Name},
+		{"XDG_RUNTIME_DIR", runtimeDir},
+		{"DBUS_SESSION_BUS_ADDRESS", "unix:path=" + runtimeDir + "/bus"},
+	}
+
+	env := make([]string, 0, len(base)+len(vars))
+	for _, kv := range base {
+		k, _, _ := strings.Cut(kv, "=")
+		if !slices.ContainsFunc(vars, func(v [2]string) bool { return v[0] == k }) {
+			env = append(env, kv)
+		}
+	}
+	for _, v := range vars {
+		env = append(env, v[0]+"="+v[1])
+	}
+	return env
+}
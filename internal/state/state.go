@@ -0,0 +1,174 @@
+// Package state remembers, across sessions, what yafti already did for
+// the current user: the last result of every action, and whether the
+// first run setup was completed.
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/Zeglius/yafti-go/config"
+	"github.com/Zeglius/yafti-go/internal/xdg"
+)
+
+const (
+	// Result of an action that ran successfully
+	ResultSuccess = "success"
+	// Result of an action skipped because it was already applied
+	ResultSkipped = "skipped"
+	// Result of an action undone by its undo or uninstall script
+	ResultReverted = "reverted"
+)
+
+// ActionState is the last recorded run of an action.
+type ActionState struct {
+	LastRun  time.Time `json:"last_run"`
+	Result   string    `json:"result"`
+	ExitCode int       `json:"exit_code"`
+	// [config.Action.Hash] of the action when it ran. Empty once removed
+	// with its uninstall or undo script.
+	ConfigHash string `json:"config_hash"`
+}
+
+// Completed marks the first run setup as done.
+type Completed struct {
+	At time.Time `json:"at"`
+	// [config.Config.Hash] of the config when it was completed
+	ConfigHash string `json:"config_hash"`
+}
+
+type data struct {
+	Completed *Completed             `json:"completed,omitempty"`
+	Actions   map[string]ActionState `json:"actions"`
+}
+
+// Store is the state file of the current user. It is safe for concurrent use.
+type Store struct {
+	mu   sync.Mutex
+	path string
+	data data
+}
+
+// DefaultPath returns the location of the state file, under $XDG_STATE_HOME.
+func DefaultPath() (string, error) {
+	dir, err := xdg.StateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "state.json"), nil
+}
+
+// Open reads the state file at path. A missing file is an empty state.
+func Open(path string) (*Store, error) {
+	s := &Store{path: path}
+
+	b, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, err
+	default:
+		if err := json.Unmarshal(b, &s.data); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.data.Actions == nil {
+		s.data.Actions = make(map[string]ActionState)
+	}
+	return s, nil
+}
+
+// Action returns the last recorded run of the action with the given ID.
+func (s *Store) Action(id string) (ActionState, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.data.Actions[id]
+	return st, ok
+}
+
+// RecordAction saves the result of a run of the action with the given ID.
+func (s *Store) RecordAction(id string, st ActionState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Actions[id] = st
+	return s.saveLocked()
+}
+
+// Completed returns the completed marker, if the setup was completed.
+func (s *Store) Completed() (Completed, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.data.Completed == nil {
+		return Completed{}, false
+	}
+	return *s.data.Completed, true
+}
+
+// MarkCompleted records the first run setup as done for the config with
+// the given hash.
+func (s *Store) MarkCompleted(configHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Completed = &Completed{At: time.Now(), ConfigHash: configHash}
+	return s.saveLocked()
+}
+
+// Done reports whether act already ran successfully in its current version.
+func (s *Store) Done(act config.Action) bool {
+	st, ok := s.Action(act.ID)
+	return ok && st.Result == ResultSuccess && st.ConfigHash == act.Hash()
+}
+
+// Remaining returns a copy of conf with only what should still be shown
+// to the user:
+//   - Actions with run_once (or in a screen with run_once) are hidden once
+//     they ran successfully.
+//   - After the setup is completed, only screens and actions with
+//     always_show are kept.
+//
+// Screens left without actions are dropped.
+func (s *Store) Remaining(conf *config.Config) *config.Config {
+	_, completed := s.Completed()
+
+	res := *conf
+	res.Screens = nil
+	for _, screen := range conf.Screens {
+		var actions []config.Action
+		for _, act := range screen.Actions {
+			if completed && !screen.AlwaysShow && !act.AlwaysShow {
+				continue
+			}
+			if (screen.RunOnce || act.RunOnce) && s.Done(act) {
+				continue
+			}
+			actions = append(actions, act)
+		}
+		if len(actions) > 0 {
+			screen.Actions = actions
+			res.Screens = append(res.Screens, screen)
+		}
+	}
+	return &res
+}
+
+// saveLocked writes the state file atomically. s.mu must be held.
+func (s *Store) saveLocked() error {
+	b, err := json.MarshalIndent(s.data, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
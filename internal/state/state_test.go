@@ -0,0 +1,72 @@
+package state
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Zeglius/yafti-go/config"
+)
+
+func TestRemaining(t *testing.T) {
+	once := config.Action{ID: "once", Title: "Once", Script: "true", RunOnce: true}
+	always := config.Action{ID: "always", Title: "Always", Script: "true", AlwaysShow: true}
+	plain := config.Action{ID: "plain", Title: "Plain", Script: "true"}
+	conf := &config.Config{Screens: []config.Screen{
+		{Title: "First", Actions: []config.Action{once, always, plain}},
+		{Title: "Second", RunOnce: true, Actions: []config.Action{{ID: "screen-once", Title: "Screen once", Script: "true"}}},
+	}}
+	done := func(act config.Action) ActionState {
+		return ActionState{Result: ResultSuccess, ConfigHash: act.Hash()}
+	}
+
+	for _, tt := range []struct {
+		name      string
+		actions   map[string]ActionState
+		completed bool
+		want      []string // IDs of the actions left
+	}{
+		{"nothing ran", nil, false, []string{"once", "always", "plain", "screen-once"}},
+		{"run_once done", map[string]ActionState{
+			"once":        done(once),
+			"screen-once": done(conf.Screens[1].Actions[0]),
+		}, false, []string{"always", "plain"}},
+		{"run_once failed", map[string]ActionState{
+			"once": {Result: "failed", ConfigHash: once.Hash()},
+		}, false, []string{"once", "always", "plain", "screen-once"}},
+		{"run_once reverted", map[string]ActionState{
+			"once": {Result: ResultReverted},
+		}, false, []string{"once", "always", "plain", "screen-once"}},
+		{"run_once changed since", map[string]ActionState{
+			"once": {Result: ResultSuccess, ConfigHash: "an older version"},
+		}, false, []string{"once", "always", "plain", "screen-once"}},
+		{"completed", nil, true, []string{"always"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Open(filepath.Join(t.TempDir(), "state.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for id, st := range tt.actions {
+				st.LastRun = time.Now()
+				if err := s.RecordAction(id, st); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.completed {
+				if err := s.MarkCompleted(conf.Hash()); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var got []string
+			for act := range s.Remaining(conf).GetAllActions() {
+				got = append(got, act.ID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+// Package wrapper runs and supervises the command that opens the interface
+// (usually a kiosk browser), so its lifetime is tied to the server.
+package wrapper
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"github.com/labstack/gommon/log"
+)
+
+const (
+	// A wrapper exiting successfully faster than this is assumed to have
+	// handed the URL to an already open browser (e.g. xdg-open), so there
+	// is nothing left to supervise.
+	detachThreshold = 3 * time.Second
+	// Relaunch delays double after every crash, up to this value
+	maxBackoff = 30 * time.Second
+	// A wrapper running longer than this resets the relaunch delay
+	stableAfter = time.Minute
+)
+
+// Supervisor keeps the wrapper command running alongside the server.
+type Supervisor struct {
+	Cmd string // Shell command to run, with the URL already substituted
+	// Busy reports whether a run is in progress. While it is, the wrapper
+	// is relaunched whenever it exits, unless it detached.
+	Busy func() bool
+	// OnIdleExit is called when the wrapper exits while nothing is running,
+	// i.e. the user closed it.
+	OnIdleExit func()
+}
+
+// Run starts the wrapper and supervises it until ctx is cancelled, which
+// also terminates the wrapper.
+func (s *Supervisor) Run(ctx context.Context) {
+	backoff := time.Second
+
+	for {
+		started := time.Now()
+		err := s.runOnce(ctx)
+		elapsed := time.Since(started)
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		var exitErr *exec.ExitError
+		switch {
+		case err == nil:
+			log.Infof("Wrapper exited successfully after %s", elapsed.Round(time.Millisecond))
+		case errors.As(err, &exitErr):
+			log.Warnf("Wrapper exited after %s: %v", elapsed.Round(time.Millisecond), exitErr)
+		default:
+			log.Errorf("Wrapper failed: %v", err)
+		}
+
+		// Checked first: relaunching a wrapper that hands the URL over
+		// would open another window every time
+		if err == nil && elapsed < detachThreshold {
+			log.Info("Wrapper detached from the browser, no longer supervising it")
+			return
+		}
+
+		if s.Busy() {
+			if elapsed > stableAfter {
+				backoff = time.Second
+			}
+			log.Infof("A run is in progress, relaunching wrapper in %s", backoff)
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return
+			}
+			backoff = min(backoff*2, maxBackoff)
+			continue
+		}
+
+		log.Info("Wrapper exited while idle, shutting down")
+		s.OnIdleExit()
+		return
+	}
+}
+
+func (s *Supervisor) runOnce(ctx context.Context) error {
+	cmd := exec.CommandContext(ctx, "sh", "-c", s.Cmd)
+	// Run in its own process group, so the browser spawned by the shell
+	// is terminated along with it.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+	}
+	cmd.WaitDelay = 5 * time.Second
+
+	return cmd.Run()
+}
@@ -0,0 +1,74 @@
+// Package xdg resolves the per-user directories yafti stores its files in,
+// following the XDG base directory specification.
+package xdg
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+)
+
+// Name of the subdirectory used inside every base directory
+const appDir = "yafti"
+
+// RuntimeDir returns the directory for sockets and other runtime files,
+// creating it if needed. Falls back to a per-user temp directory when
+// $XDG_RUNTIME_DIR is not set.
+func RuntimeDir() (string, error) {
+	base := os.Getenv("XDG_RUNTIME_DIR")
+	if base == "" {
+		// Other users can create it first in the shared temp directory, to
+		// take over the instance socket and lock
+		base = filepath.Join(os.TempDir(), appDir+"-"+strconv.Itoa(os.Getuid()))
+		if err := os.Mkdir(base, 0o700); err != nil && !errors.Is(err, os.ErrExist) {
+			return "", err
+		}
+		if err := checkPrivate(base); err != nil {
+			return "", err
+		}
+	}
+	return mkdir(filepath.Join(base, appDir))
+}
+
+// checkPrivate returns an error unless dir is a directory, not a symlink,
+// owned by the current user and only accessible by them.
+func checkPrivate(dir string) error {
+	fi, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	switch {
+	case !fi.IsDir():
+		return fmt.Errorf("%s is not a directory", dir)
+	case !ok || int(st.Uid) != os.Getuid():
+		return fmt.Errorf("%s is not owned by the current user", dir)
+	case fi.Mode().Perm() != 0o700:
+		return fmt.Errorf("%s has mode %04o, not 0700", dir, fi.Mode().Perm())
+	}
+	return nil
+}
+
+// StateDir returns the directory for persistent state, creating it if
+// needed. Defaults to ~/.local/state/yafti.
+func StateDir() (string, error) {
+	base := os.Getenv("XDG_STATE_HOME")
+	if base == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		base = filepath.Join(home, ".local", "state")
+	}
+	return mkdir(filepath.Join(base, appDir))
+}
+
+func mkdir(dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
@@ -0,0 +1,63 @@
+package xdg
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestRuntimeDirFallback(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		prepare func(t *testing.T, base string)
+		wantErr bool
+	}{
+		{"created", func(t *testing.T, base string) {}, false},
+		{"existing", func(t *testing.T, base string) {
+			if err := os.Mkdir(base, 0o700); err != nil {
+				t.Fatal(err)
+			}
+		}, false},
+		{"open to others", func(t *testing.T, base string) {
+			if err := os.Mkdir(base, 0o777); err != nil {
+				t.Fatal(err)
+			}
+			os.Chmod(base, 0o777)
+		}, true},
+		{"symlink", func(t *testing.T, base string) {
+			target := t.TempDir()
+			os.Chmod(target, 0o700)
+			if err := os.Symlink(target, base); err != nil {
+				t.Fatal(err)
+			}
+		}, true},
+		{"file", func(t *testing.T, base string) {
+			if err := os.WriteFile(base, nil, 0o600); err != nil {
+				t.Fatal(err)
+			}
+		}, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			tmp := t.TempDir()
+			t.Setenv("TMPDIR", tmp)
+			t.Setenv("XDG_RUNTIME_DIR", "")
+			base := filepath.Join(tmp, appDir+"-"+strconv.Itoa(os.Getuid()))
+			tt.prepare(t, base)
+
+			dir, err := RuntimeDir()
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("got %s, want an error", dir)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := filepath.Join(base, appDir); dir != want {
+				t.Errorf("got %s, want %s", dir, want)
+			}
+		})
+	}
+}
@@ -2,52 +2,281 @@ package main
 
 import (
 	"embed"
-	"log"
-	"net/http"
+	"errors"
+	"flag"
+	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
+	"time"
 
-	"github.com/Zeglius/yafti-go/internal/consts"
-	srv "github.com/Zeglius/yafti-go/server"
-	"golang.org/x/sync/errgroup"
+	"github.com/Zeglius/yafti-go/config"
+	"github.com/Zeglius/yafti-go/executor"
+	"github.com/Zeglius/yafti-go/internal/autostart"
+	"github.com/Zeglius/yafti-go/internal/history"
+	"github.com/Zeglius/yafti-go/internal/journal"
+	"github.com/Zeglius/yafti-go/internal/session"
+	"github.com/Zeglius/yafti-go/internal/state"
+	"github.com/labstack/gommon/log"
 )
 
 //go:embed static/**
 var static embed.FS
 
+// Set at build time with -ldflags "-X main.version=..."
+var version = "dev"
+
+// Exit codes
+const (
+	exitOK        = 0
+	exitError     = 1 // Generic runtime error
+	exitUsage     = 2 // Invalid command line
+	exitConfig    = 3 // The config file could not be loaded or is invalid
+	exitRunFailed = 4 // At least one action failed
+)
+
+type command struct {
+	name  string
+	short string // One line description shown in the help output. Internal commands have none
+	run   func(args []string) int
+}
+
+var commands = []command{
+	{"serve", "Start the web interface (default)", cmdServe},
+	{"tui", "Start the terminal interface", cmdTUI},
+	{"validate", "Check the config file for errors", cmdValidate},
+	{"run", "Run actions by ID without any interface", cmdRun},
+	{"list", "List the screens and actions of the config file", cmdList},
+	{"schema", "Print the JSON schema of the config file", cmdSchema},
+	{"version", "Print the version", cmdVersion},
+	{"privileged-helper", "", cmdPrivilegedHelper},
+}
+
 func main() {
-	// Get the wrapper command from environment variables
-	// If YAFTI_EXEC_WRAPPER is set, the server will be started and the wrapper command will be executed
-	cmd := os.Getenv("YAFTI_EXEC_WRAPPER")
+	args := os.Args[1:]
+
+	// Without a subcommand, behave as `yafti serve`
+	name := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	} else if len(args) > 0 && (args[0] == "-h" || args[0] == "-help" || args[0] == "--help") {
+		name = "help"
+	}
+
+	if name == "help" {
+		usage()
+		os.Exit(exitOK)
+	}
+
+	for _, cmd := range commands {
+		if cmd.name == name {
+			os.Exit(cmd.run(args))
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "yafti: unknown command %q\n\n", name)
+	usage()
+	os.Exit(exitUsage)
+}
 
-	// Instantiate server
-	server := srv.New()
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: yafti [command] [flags]\n\nCommands:\n")
+	for _, cmd := range commands {
+		if cmd.short != "" {
+			fmt.Fprintf(os.Stderr, "  %-10s %s\n", cmd.name, cmd.short)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "\nRun 'yafti <command> --help' for the flags of each command.\n")
+}
+
+// envOr returns the value of the environment variable key, or def if unset.
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
 
-	// Load static assets
-	server.StaticAssets = &static
+// newFlagSet creates the flag set of a subcommand, with a consistent help output.
+func newFlagSet(name, args, short string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: yafti %s [flags]%s\n\n%s\n", name, args, short)
+		hasFlags := false
+		fs.VisitAll(func(*flag.Flag) { hasFlags = true })
+		if hasFlags {
+			fmt.Fprintf(fs.Output(), "\nFlags:\n")
+			fs.PrintDefaults()
+		}
+	}
+	return fs
+}
 
-	// If no wrapper command is provided, just run the server directly...
-	if cmd == "" {
-		if err := server.Start(); err != nil && err != http.ErrServerClosed {
-			log.Panic(err)
+// parseFlags parses args into fs. It returns false and the exit code to
+// use if the command should not continue.
+func parseFlags(fs *flag.FlagSet, args []string) (int, bool) {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK, false
 		}
-		return
+		return exitUsage, false
 	}
+	return exitOK, true
+}
+
+// Flags shared by all the commands that need a config file.
+type commonFlags struct {
+	configPath string
+	logLevel   string
+	lvl        log.Lvl // Parsed from logLevel by setup
+}
 
-	// ... else, we start the server and execute the wrapper command.
-	cmd = strings.ReplaceAll(cmd, "%u", "http://localhost:"+consts.PORT)
+func addCommonFlags(fs *flag.FlagSet) *commonFlags {
+	f := &commonFlags{}
+	fs.StringVar(&f.configPath, "config", envOr("YAFTI_CONF", config.DefaultPath), "path of the config file (env: YAFTI_CONF)")
+	fs.StringVar(&f.logLevel, "log-level", envOr("YAFTI_LOG_LEVEL", "info"), "log verbosity: debug, info, warn, error or off (env: YAFTI_LOG_LEVEL)")
+	return f
+}
+
+// setup applies the log level and loads the config file.
+// It returns the exit code to use on failure.
+func (f *commonFlags) setup() (int, bool) {
+	lvl, err := parseLogLevel(f.logLevel)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "yafti: %v\n", err)
+		return exitUsage, false
+	}
+	log.SetLevel(lvl)
+	f.lvl = lvl
+	// stdout is for the output of commands and the replies of the
+	// privileged helper
+	log.SetOutput(os.Stderr)
+	log.SetHeader("${time_rfc3339} ${level}")
 
-	// Start the server and start the server wrapper command, alongside
-	// executing the wrapper command.
-	var errg errgroup.Group
-	errg.Go(func() error {
-		server.Start()
-		return nil
-	})
-	errg.Go(exec.Command("sh", "-c", cmd).Start)
+	if err := config.LoadConfig(f.configPath); err != nil {
+		fmt.Fprintf(os.Stderr, "yafti: failed to load config %s:\n%v\n", f.configPath, err)
+		return exitConfig, false
+	}
+	return exitOK, true
+}
+
+// openState opens the state file, run journal and history of the user for
+// the executor to record into. Unless force is set, [config.ConfStatus] is
+// reduced to what is still relevant according to it (see
+// [state.Store.Remaining]).
+//
+// It returns false and the exit code to use if there is nothing to show.
+func openState(ex *executor.Executor, force bool) (int, bool) {
+	var err error
+	if ex.Journal, err = journal.Open(); err != nil {
+		// Not fatal either, runs just can't be resumed
+		log.Warnf("Failed to open run journal: %v", err)
+	}
+	if ex.History, err = history.Open(); err != nil {
+		log.Warnf("Failed to open run history: %v", err)
+	} else {
+		ex.History.Limit = config.ConfStatus.HistoryLimit
+	}
+
+	path, err := state.DefaultPath()
+	if err == nil {
+		ex.State, err = state.Open(path)
+	}
+	if err != nil {
+		// Not fatal, the user just gets everything shown
+		log.Warnf("Failed to open state file: %v", err)
+		return exitOK, true
+	}
+
+	if force {
+		return exitOK, true
+	}
+
+	config.ConfStatus = ex.State.Remaining(config.ConfStatus)
+	if len(config.ConfStatus.Screens) == 0 && len(ex.Pending()) == 0 {
+		fmt.Fprintln(os.Stderr, "yafti: setup already completed, nothing to show. Use --force to show everything.")
+		return exitOK, false
+	}
+	return exitOK, true
+}
+
+// newExecutor returns an executor for the config file at configPath, set
+// up to run each action as the user it is meant to.
+func newExecutor(configPath string, grace time.Duration) *executor.Executor {
+	ex := executor.New()
+	ex.GracePeriod = grace
+	if n := config.ConfStatus.MaxParallel; n > 0 {
+		ex.MaxParallel = n
+	}
+	ex.OnFailure = config.ConfStatus.OnFailure
+	if abs, err := filepath.Abs(configPath); err == nil {
+		ex.ConfigDir = filepath.Dir(abs)
+	}
+	if _, err := exec.LookPath("pkexec"); err == nil {
+		ex.PrivilegedHelper = privilegedHelperCommand(configPath, grace)
+	}
+
+	if ex.Root() {
+		u, err := session.Active()
+		if err != nil {
+			// Only root actions can run, see executor.ErrNoSessionUser
+			log.Warnf("Running as root, but the session user is unknown: %v", err)
+		} else {
+			log.Infof("Running as root, user actions run as %s", u.Name)
+			ex.SessionUser = u
+		}
+	}
+	return ex
+}
+
+// privilegedHelperCommand returns the command line starting the privileged
+// helper as root, with the same config file and grace period.
+func privilegedHelperCommand(configPath string, grace time.Duration) []string {
+	exe, err := os.Executable()
+	if err != nil {
+		exe = "yafti"
+	}
+	if abs, err := filepath.Abs(configPath); err == nil {
+		configPath = abs
+	}
+	return []string{"pkexec", exe, "privileged-helper", "--config", configPath, "--grace-period", grace.String()}
+}
+
+// resumeCommand returns the command line relaunching yafti to resume the
+// unfinished runs, for the autostart entry written before a reboot.
+func resumeCommand(configPath, wrapper string) string {
+	exe, err := os.Executable()
+	if err != nil {
+		exe = "yafti"
+	}
+	if abs, err := filepath.Abs(configPath); err == nil {
+		configPath = abs
+	}
+
+	args := []string{exe, "serve", "--resume", "--config", configPath}
+	if wrapper != "" {
+		args = append(args, "--wrapper", wrapper)
+	}
+	for i, a := range args {
+		args[i] = autostart.Quote(a)
+	}
+	return strings.Join(args, " ")
+}
 
-	if err := errg.Wait(); err != nil {
-		log.Panic(err)
+func parseLogLevel(s string) (log.Lvl, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return log.DEBUG, nil
+	case "info":
+		return log.INFO, nil
+	case "warn", "warning":
+		return log.WARN, nil
+	case "error":
+		return log.ERROR, nil
+	case "off":
+		return log.OFF, nil
+	default:
+		return 0, fmt.Errorf("invalid log level %q", s)
 	}
 }
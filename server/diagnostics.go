@@ -0,0 +1,74 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"syscall"
+
+	"github.com/Zeglius/yafti-go/internal/history"
+)
+
+// Number of output lines of a failed action included in diagnostics
+const diagnosticsTail = 200
+
+// writeDiagnostics writes what is useful in a bug report about a run:
+// the system yafti runs on, and the end of the output of the failed actions.
+func (s *Server) writeDiagnostics(w io.Writer, rec history.Record) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "yafti %s (%s/%s)\n", s.Version, runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(&b, "OS: %s\n", osName())
+	var uts syscall.Utsname
+	if err := syscall.Uname(&uts); err == nil {
+		fmt.Fprintf(&b, "Kernel: %s\n", utsString(uts.Release[:]))
+	}
+	b.WriteString("\n")
+
+	failed := rec
+	failed.Actions = nil
+	for _, a := range rec.Actions {
+		if a.Succeeded() {
+			continue
+		}
+		if n := len(a.Output); n > diagnosticsTail {
+			a.Output = append([]string{fmt.Sprintf("[%d lines omitted]", n-diagnosticsTail)}, a.Output[n-diagnosticsTail:]...)
+		}
+		failed.Actions = append(failed.Actions, a)
+	}
+
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return err
+	}
+	return failed.WriteTranscript(w)
+}
+
+// osName returns the pretty name of the distribution from os-release.
+func osName() string {
+	f, err := os.Open("/etc/os-release")
+	if err != nil {
+		return "unknown"
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if v, ok := strings.CutPrefix(sc.Text(), "PRETTY_NAME="); ok {
+			return strings.Trim(v, `"'`)
+		}
+	}
+	return "unknown"
+}
+
+func utsString[T int8 | uint8](field []T) string {
+	var b strings.Builder
+	for _, c := range field {
+		if c == 0 {
+			break
+		}
+		b.WriteByte(byte(c))
+	}
+	return b.String()
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/Zeglius/yafti-go/executor"
+	"github.com/Zeglius/yafti-go/internal/notify"
+	"github.com/labstack/gommon/log"
+)
+
+// notifyRun tells the user with desktop notifications when run waits for
+// them, and when it finishes, as the interface may be hidden. Every
+// notification of the run replaces the previous one.
+func (s *Server) notifyRun(run *executor.Run) {
+	var id uint32
+	send := func(msg notify.Notification) {
+		msg.Replaces = id
+		if s.Reopen != nil {
+			msg.OnClick = func() { s.Reopen(s.URL() + "/runs/" + run.ID) }
+		}
+		var err error
+		if id, err = s.Notifier.Send(msg); err != nil {
+			log.Warnf("Run %s: failed to send notification: %v", run.ID, err)
+		}
+	}
+
+	// Not following the run once the server shuts down
+	for ev := range run.Events(s.shutdownCtx) {
+		switch ev.Kind {
+		case executor.DecisionRequired:
+			send(notify.Notification{
+				Summary: run.Actions[ev.Index].Title + " failed",
+				Body:    "Choose whether to retry it, skip it, or abort the rest.",
+				Urgent:  true,
+			})
+		case executor.TerminalOpened:
+			send(notify.Notification{
+				Summary: run.Actions[ev.Index].Title + " needs your input",
+				Body:    "Answer its questions in the installation page.",
+				Urgent:  true,
+			})
+		case executor.RunFinished:
+			send(finishedNotification(run))
+		}
+	}
+}
+
+// finishedNotification tells how run went, once it finished.
+func finishedNotification(run *executor.Run) notify.Notification {
+	failed, notRun, interrupted := 0, 0, 0
+	for _, res := range run.Results() {
+		switch res.Status {
+		case executor.StatusFailed, executor.StatusReverted:
+			failed++
+		case executor.StatusNotRun, executor.StatusPending:
+			notRun++
+		case executor.StatusInterrupted:
+			interrupted++
+		}
+	}
+
+	switch {
+	case run.RebootRequired():
+		return notify.Notification{Summary: "Reboot required", Body: "Reboot to finish installing the selected items."}
+	case failed > 0:
+		body := fmt.Sprintf("%d of %d items failed to install.", failed, len(run.Actions))
+		if notRun > 0 {
+			body = fmt.Sprintf("%d of %d items failed to install, %d more were not installed.", failed, len(run.Actions), notRun)
+		}
+		return notify.Notification{Summary: "Installation failed", Body: body, Urgent: true}
+	case interrupted > 0:
+		return notify.Notification{Summary: "Installation interrupted", Body: "Not every selected item was installed."}
+	default:
+		return notify.Notification{Summary: "Installation complete", Body: "Every selected item was installed."}
+	}
+}
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Zeglius/yafti-go/config"
+	"github.com/Zeglius/yafti-go/executor"
+	"github.com/Zeglius/yafti-go/internal/dbustest"
+	"github.com/Zeglius/yafti-go/internal/notify"
+	"github.com/godbus/dbus/v5"
+)
+
+// fakeNotifications stands in for the notification server of the desktop.
+type fakeNotifications struct {
+	last  uint32
+	shown chan shownNotification
+}
+
+type shownNotification struct {
+	id, replaces uint32
+	summary      string
+	actions      []string
+	urgency      byte
+}
+
+func (f *fakeNotifications) Notify(app string, replaces uint32, icon, summary, body string, actions []string, hints map[string]dbus.Variant, timeout int32) (uint32, *dbus.Error) {
+	id := replaces
+	if id == 0 {
+		f.last++
+		id = f.last
+	}
+	urgency, _ := hints["urgency"].Value().(byte)
+	f.shown <- shownNotification{id: id, replaces: replaces, summary: summary, actions: actions, urgency: urgency}
+	return id, nil
+}
+
+func TestNotifyRun(t *testing.T) {
+	addr := dbustest.Start(t)
+	daemon := dbustest.Connect(t, addr)
+	fake := &fakeNotifications{shown: make(chan shownNotification, 4)}
+	if err := daemon.Export(fake, "/org/freedesktop/Notifications", "org.freedesktop.Notifications"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := daemon.RequestName("org.freedesktop.Notifications", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	ex := executor.New()
+	ex.SystemBus = func() (*dbus.Conn, error) { return nil, errors.New("no system bus in tests") }
+	s := New(ex)
+	s.Addr = "127.0.0.1:8080"
+	var err error
+	if s.Notifier, err = notify.New(dbustest.Connect(t, addr)); err != nil {
+		t.Fatal(err)
+	}
+	reopened := make(chan string, 1)
+	s.Reopen = func(url string) { reopened <- url }
+
+	act := config.Action{ID: "fail", Title: "Broken", Script: "exit 1", OnFailure: config.OnFailureAsk}
+	if ex.Root() {
+		act.RunAs = "root"
+	}
+	run, err := ex.Start([]config.Action{act})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(run.Cancel)
+	go s.notifyRun(run)
+
+	next := func() shownNotification {
+		t.Helper()
+		select {
+		case n := <-fake.shown:
+			return n
+		case <-time.After(5 * time.Second):
+			t.Fatal("no notification shown")
+			return shownNotification{}
+		}
+	}
+
+	waiting := next()
+	if waiting.summary != "Broken failed" || waiting.urgency != 2 || waiting.replaces != 0 {
+		t.Errorf("waiting for a decision, got %+v", waiting)
+	}
+	if err := run.Decide(0, executor.DecisionSkip); err != nil {
+		t.Fatal(err)
+	}
+
+	finished := next()
+	if finished.summary != "Installation failed" || finished.replaces != waiting.id {
+		t.Errorf("finished, got %+v, want it to replace notification %d", finished, waiting.id)
+	}
+	if len(finished.actions) == 0 || finished.actions[0] != "default" {
+		t.Errorf("finished notification can't be clicked, actions %q", finished.actions)
+	}
+
+	// The user clicks it
+	err = daemon.Emit("/org/freedesktop/Notifications", "org.freedesktop.Notifications.ActionInvoked", finished.id, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case url := <-reopened:
+		if want := "http://127.0.0.1:8080/runs/" + run.ID; url != want {
+			t.Errorf("reopened %s, want %s", url, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("interface not reopened on click")
+	}
+}
+
+func TestFinishedNotificationCountsNotRun(t *testing.T) {
+	ex := executor.New()
+	ex.SystemBus = func() (*dbus.Conn, error) { return nil, errors.New("no system bus in tests") }
+
+	actions := []config.Action{
+		{ID: "fail", Title: "Broken", Script: "exit 1", OnFailure: config.OnFailureStop},
+		{ID: "next", Title: "Next", Script: "true"},
+		{ID: "last", Title: "Last", Script: "true"},
+	}
+	if ex.Root() {
+		for i := range actions {
+			actions[i].RunAs = "root"
+		}
+	}
+	run, err := ex.Start(actions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-run.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("run did not finish")
+	}
+
+	n := finishedNotification(run)
+	if want := "1 of 3 items failed to install, 2 more were not installed."; n.Summary != "Installation failed" || n.Body != want {
+		t.Errorf("got %q: %q, want %q", n.Summary, n.Body, want)
+	}
+}
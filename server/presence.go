@@ -0,0 +1,115 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+)
+
+// How often a comment is sent over idle event streams, so proxies and
+// browsers don't consider them dead.
+const keepaliveInterval = 15 * time.Second
+
+// idleLocked reports whether nothing keeps the server alive. s.m must be held.
+func (s *Server) idleLocked() bool {
+	return s.clients == 0 && !s.exec.Inhibitor().Active()
+}
+
+// updateIdleLocked arms the idle timer when there is no client connected
+// and nothing inhibits the shutdown, and disarms it otherwise. s.m must be held.
+func (s *Server) updateIdleLocked() {
+	if s.idleLocked() && s.IdleTimeout > 0 {
+		if s.idleTimer == nil {
+			s.idleTimer = time.AfterFunc(s.IdleTimeout, s.idleShutdown)
+		}
+		return
+	}
+	if s.idleTimer != nil {
+		s.idleTimer.Stop()
+		s.idleTimer = nil
+	}
+}
+
+func (s *Server) idleShutdown() {
+	s.m.Lock()
+	if s.idleTimer == nil || !s.idleLocked() {
+		// A client came back or a run started in the meantime
+		s.m.Unlock()
+		return
+	}
+	s.idleTimer = nil
+	s.m.Unlock()
+
+	log.Infof("No client connected for %s, shutting down server", s.IdleTimeout)
+	if err := s.Shutdown(context.Background()); err != nil {
+		log.Errorf("Shutdown error: %v", err)
+	}
+}
+
+// inhibitorsHandler reports what keeps the server alive, for debugging.
+func (s *Server) inhibitorsHandler(c echo.Context) error {
+	s.m.Lock()
+	clients := s.clients
+	idleTimer := s.idleTimer != nil
+	s.m.Unlock()
+
+	inhibit := s.exec.Inhibitor()
+	return c.JSON(http.StatusOK, struct {
+		Inhibited   bool     `json:"inhibited"`
+		Reasons     []string `json:"reasons"`
+		Clients     int      `json:"clients"`
+		IdleTimeout string   `json:"idle_timeout"`
+		IdleTimer   bool     `json:"idle_timer_armed"`
+	}{
+		Inhibited:   inhibit.Active(),
+		Reasons:     inhibit.Reasons(),
+		Clients:     clients,
+		IdleTimeout: s.IdleTimeout.String(),
+		IdleTimer:   idleTimer,
+	})
+}
+
+// eventsHandler keeps a Server-Sent Events stream open for as long as the
+// page is open. Open streams are how the server knows clients are around,
+// and they are used to tell them when the server shuts down.
+func (s *Server) eventsHandler(c echo.Context) error {
+	w := c.Response()
+	w.Header().Set(echo.HeaderContentType, "text/event-stream")
+	w.Header().Set(echo.HeaderCacheControl, "no-cache")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	w.Flush()
+
+	s.m.Lock()
+	s.clients++
+	s.updateIdleLocked()
+	s.m.Unlock()
+
+	defer func() {
+		s.m.Lock()
+		s.clients--
+		s.updateIdleLocked()
+		s.m.Unlock()
+	}()
+
+	ticker := time.NewTicker(keepaliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.Request().Context().Done():
+			return nil
+		case <-s.shutdownCtx.Done():
+			fmt.Fprint(w, "event: shutdown\ndata: yafti has shut down\n\n")
+			w.Flush()
+			return nil
+		case <-ticker.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+			w.Flush()
+		}
+	}
+}
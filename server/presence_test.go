@@ -0,0 +1,61 @@
+package server
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Zeglius/yafti-go/config"
+	"github.com/Zeglius/yafti-go/executor"
+	"github.com/godbus/dbus/v5"
+)
+
+func TestIdleShutdownWaitsForRuns(t *testing.T) {
+	const timeout = 50 * time.Millisecond
+
+	ex := executor.New()
+	ex.GracePeriod = 100 * time.Millisecond
+	ex.SystemBus = func() (*dbus.Conn, error) { return nil, errors.New("no system bus in tests") }
+	s := New(ex)
+	s.IdleTimeout = timeout
+
+	act := config.Action{ID: "wait", Title: "Wait", Script: "sleep 30"}
+	if ex.Root() {
+		act.RunAs = "root"
+	}
+	run, err := ex.Start([]config.Action{act})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(run.Cancel)
+
+	// No client is connected, as when the last page was closed
+	s.m.Lock()
+	s.updateIdleLocked()
+	armed := s.idleTimer != nil
+	s.m.Unlock()
+	if armed {
+		t.Fatal("idle timer armed while a run is in progress")
+	}
+	if !ex.Inhibitor().Active() {
+		t.Fatal("run in progress does not hold the inhibitor")
+	}
+
+	select {
+	case <-s.shutdownCtx.Done():
+		t.Fatal("server shut down while a run is in progress")
+	case <-time.After(4 * timeout):
+	}
+
+	run.Cancel()
+	<-run.Done()
+
+	select {
+	case <-s.shutdownCtx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not shut down once the run finished")
+	}
+	if ex.Inhibitor().Active() {
+		t.Fatal("finished run still holds the inhibitor")
+	}
+}
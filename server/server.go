@@ -5,29 +5,49 @@ import (
 	"embed"
 	"encoding/json"
 	"errors"
-	"log"
+	"net"
 	"net/http"
 	"net/url"
+	"os"
+	"os/exec"
 	"slices"
 	"strconv"
 	"sync"
 	"time"
 
 	"github.com/Zeglius/yafti-go/config"
+	"github.com/Zeglius/yafti-go/executor"
 	"github.com/Zeglius/yafti-go/internal/consts"
+	"github.com/Zeglius/yafti-go/internal/history"
+	"github.com/Zeglius/yafti-go/internal/journal"
+	"github.com/Zeglius/yafti-go/internal/notify"
 	"github.com/Zeglius/yafti-go/ui/pages"
 	"github.com/a-h/templ"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"github.com/labstack/gommon/log"
 )
 
 type Server struct {
 	e            *echo.Echo
-	lastBeat     time.Time
 	m            sync.Mutex
+	clients      int         // Open event streams
+	idleTimer    *time.Timer // Armed while there is no client and nothing inhibits the shutdown
 	shutdownCtx  context.Context
 	cancel       context.CancelFunc
-	StaticAssets *embed.FS // This var is set in main.go
+	exec         *executor.Executor
+	StaticAssets *embed.FS        // This var is set in main.go
+	Addr         string           // Address to listen on, in the form "host:port". Port 0 picks a free one
+	SocketPath   string           // If set, also listen on a Unix socket at this path
+	IdleTimeout  time.Duration    // Shut down after being idle this long. Zero disables it
+	Version      string           // Shown in diagnostics
+	DryRun       bool             // Only show what runs would do, never execute them
+	Manage       bool             // Preselect installed actions, and remove the ones turned off
+	Notifier     *notify.Notifier // If set, tells the user when runs finish or wait for them
+	Reopen       func(url string) // Opens the interface at url again, when a notification is clicked
+	listener     net.Listener
+	socket       net.Listener
+	socketSrv    *http.Server
 }
 
 // Default [templ.Handler] with streaming enabled by default
@@ -37,53 +57,244 @@ func newHandler(c templ.Component, options ...func(*templ.ComponentHandler)) *te
 	return templ.Handler(c, opts...)
 }
 
-func New() *Server {
+func New(exec *executor.Executor) *Server {
 	e := echo.New()
 	ctx, cancel := context.WithCancel(context.Background())
 
-	return &Server{
+	s := &Server{
 		e:           e,
-		lastBeat:    time.Now(),
 		shutdownCtx: ctx,
 		cancel:      cancel,
+		exec:        exec,
+		Addr:        net.JoinHostPort(consts.HOST, consts.PORT),
+		IdleTimeout: consts.IDLE_TIMEOUT,
 	}
+
+	// Runs keep the server alive while they are in progress
+	exec.Inhibitor().OnChange(func() {
+		s.m.Lock()
+		s.updateIdleLocked()
+		s.m.Unlock()
+	})
+
+	return s
 }
 
-func (s *Server) heartbeatHandler(c echo.Context) error {
-	s.m.Lock()
-	s.lastBeat = time.Now()
-	s.m.Unlock()
-	return c.String(http.StatusOK, "Hearthbeat received")
+// markCompleted records the first run setup as done, if state is kept.
+func (s *Server) markCompleted() {
+	if s.exec.State == nil {
+		return
+	}
+	if err := s.exec.State.MarkCompleted(config.ConfStatus.Hash()); err != nil {
+		log.Errorf("Failed to mark setup as completed: %v", err)
+	}
 }
 
-func (s *Server) monitorHeartbeat() {
-	ticker := time.NewTicker(5 * time.Second)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-s.shutdownCtx.Done():
-			return
-		case <-ticker.C:
-			s.m.Lock()
-			if time.Since(s.lastBeat) > consts.HEARTBEAT_SECONDS*time.Second && !config.Inhibit.Load() {
-				log.Printf("No heartbeat for %d seconds, shutting down server\n", consts.HEARTBEAT_SECONDS)
-				s.m.Unlock()
-				s.cancel()
-				if err := s.e.Shutdown(context.Background()); err != nil {
-					log.Printf("Shutdown error: %v", err)
-				}
-				return
+// selectedActions returns the actions with the given IDs that have a
+// script to execute, in config order, or an HTTP error if there is none.
+func selectedActions(ids []string) ([]config.Action, error) {
+	if len(ids) == 0 {
+		log.Debugf("No script IDs provided in request")
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "No script IDs provided")
+	}
+
+	// Get actions corresponding to the script IDs
+	actions, found := config.ConfStatus.GetActionsByIds(ids)
+	if !found || len(actions) == 0 {
+		log.Debugf("No actions found for the provided script IDs")
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "No actions found for the provided script IDs")
+	}
+
+	// Only keep the actions that have something to execute
+	actions = slices.DeleteFunc(actions, func(a config.Action) bool {
+		return a.Script == ""
+	})
+
+	if len(actions) == 0 {
+		log.Debugf("No scripts found in the selected actions")
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Selected actions contain no scripts to execute")
+	}
+	return actions, nil
+}
+
+// confirmChanges renders the confirm page for the toggles submitted from
+// a screen, "true" or "false" by action ID. In manage mode, actions turned
+// on are only installed if they are not yet, and installed actions turned
+// off are removed.
+func (s *Server) confirmChanges(c echo.Context, toggles map[string]string) error {
+	var on, off []string
+	for id, state := range toggles {
+		if state == "true" {
+			on = append(on, id)
+		} else {
+			off = append(off, id)
+		}
+	}
+
+	actions, _ := config.ConfStatus.GetActionsByIds(on)
+	var removals []config.Action
+	if s.Manage {
+		turnedOff, _ := config.ConfStatus.GetActionsByIds(off)
+		installed := s.exec.Installed(c.Request().Context(), append(slices.Clone(actions), turnedOff...))
+		actions = slices.DeleteFunc(actions, func(a config.Action) bool {
+			return installed[a.ID]
+		})
+		for _, act := range turnedOff {
+			if installed[act.ID] && act.RemovalScript() != "" {
+				removals = append(removals, act)
 			}
-			s.m.Unlock()
 		}
 	}
+
+	handler := newHandler(pages.ConfirmChanges(actions, removals))
+	handler.ServeHTTP(c.Response(), c.Request())
+	return nil
+}
+
+// startError turns an error starting a run into an HTTP error.
+func startError(err error) error {
+	log.Errorf("Failed to start run: %v", err)
+	if errors.Is(err, executor.ErrShuttingDown) {
+		return echo.NewHTTPError(http.StatusServiceUnavailable, "Yafti is shutting down")
+	}
+	// The actions can't run as the user they are meant to
+	return echo.NewHTTPError(http.StatusForbidden, err.Error())
+}
+
+// follow completes the first run setup once run fully succeeds, and
+// notifies the user of how it goes.
+func (s *Server) follow(run *executor.Run) {
+	if s.Notifier != nil {
+		go s.notifyRun(run)
+	}
+	go func() {
+		<-run.Done()
+		if run.Succeeded() {
+			s.markCompleted()
+		}
+	}()
+}
+
+// Resume starts a run with what is left of an unfinished one.
+func (s *Server) Resume(p journal.Pending) (*executor.Run, error) {
+	run, err := s.exec.Resume(p)
+	if err != nil {
+		return nil, err
+	}
+	s.follow(run)
+	return run, nil
+}
+
+// pending returns the unfinished run with the given ID.
+func (s *Server) pending(id string) (journal.Pending, bool) {
+	i := slices.IndexFunc(s.exec.Pending(), func(p journal.Pending) bool {
+		return p.RunID == id
+	})
+	if i < 0 {
+		return journal.Pending{}, false
+	}
+	return s.exec.Pending()[i], true
+}
+
+// historyRecord returns the past run with the given ID, or an HTTP error.
+func (s *Server) historyRecord(id string) (history.Record, error) {
+	if s.exec.History == nil {
+		return history.Record{}, echo.NewHTTPError(http.StatusNotFound, "Run not found")
+	}
+	rec, err := s.exec.History.Get(id)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return rec, echo.NewHTTPError(http.StatusNotFound, "Run not found")
+	case err != nil:
+		log.Errorf("Failed to read history: %v", err)
+		return rec, echo.NewHTTPError(http.StatusInternalServerError, "Failed to read history")
+	}
+	return rec, nil
+}
+
+// Shutdown gracefully stops the server, making [Server.Start] return.
+//
+// Clients with an open event stream are told the server is shutting down.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.cancel()
+	if s.socketSrv != nil {
+		if err := s.socketSrv.Shutdown(ctx); err != nil {
+			return err
+		}
+	}
+	return s.e.Shutdown(ctx)
+}
+
+// URL returns the address clients should use to reach the server.
+func (s *Server) URL() string {
+	host, port, err := net.SplitHostPort(s.Addr)
+	if err != nil {
+		return "http://" + s.Addr
+	}
+	// Wildcard addresses are not reachable, use loopback instead
+	if ip := net.ParseIP(host); host == "" || ip != nil && ip.IsUnspecified() {
+		host = "localhost"
+	}
+	return "http://" + net.JoinHostPort(host, port)
+}
+
+// SetLogLevel sets the verbosity of the server logger.
+func (s *Server) SetLogLevel(lvl log.Lvl) {
+	s.e.Logger.SetLevel(lvl)
+}
+
+// Listen binds the server to [Server.Addr] and, if set, [Server.SocketPath].
+//
+// Once it returns, [Server.Addr] holds the actual address, so [Server.URL]
+// can be used even if a free port was picked automatically.
+// [Server.Start] calls it if it was not called before.
+func (s *Server) Listen() error {
+	ln, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+
+	if s.SocketPath != "" {
+		// Remove a socket left behind by a previous instance
+		if err := os.Remove(s.SocketPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			ln.Close()
+			return err
+		}
+		sock, err := net.Listen("unix", s.SocketPath)
+		if err != nil {
+			ln.Close()
+			return err
+		}
+		if err := os.Chmod(s.SocketPath, 0o600); err != nil {
+			ln.Close()
+			sock.Close()
+			return err
+		}
+		s.socket = sock
+		s.socketSrv = &http.Server{
+			Handler: s.e,
+			ConnContext: func(ctx context.Context, _ net.Conn) context.Context {
+				return context.WithValue(ctx, socketClientKey{}, true)
+			},
+		}
+	}
+
+	s.listener = ln
+	s.Addr = ln.Addr().String()
+	return nil
 }
 
 func (s *Server) Start() error {
 	e := s.e
 
+	if s.listener == nil {
+		if err := s.Listen(); err != nil {
+			return err
+		}
+	}
+
 	e.Use(middleware.Logger())
+	e.Use(sameOriginRequests)
 
 	// Set up static file serving
 	if s.StaticAssets == nil {
@@ -92,14 +303,22 @@ func (s *Server) Start() error {
 	fs := echo.MustSubFS(*s.StaticAssets, "static")
 	e.StaticFS("/static/", fs)
 
-	// Handle heartbeat, so we shutdown the server automatically
-	// when there is no client connected over a period of time.
-	e.GET("/_/heartbeat", s.heartbeatHandler)
+	// Every open page keeps an event stream open, so we shutdown the server
+	// automatically when there is no client connected over a period of time.
+	e.GET("/_/events", s.eventsHandler)
+	e.GET("/_/debug/inhibitors", s.inhibitorsHandler)
+
+	// Lets a second yafti launch check this one is alive before handing off to it
+	e.GET("/_/ping", func(c echo.Context) error {
+		return c.String(http.StatusOK, "pong")
+	})
 
 	// Handle pages routes
-	e.GET("/", echo.WrapHandler(
-		newHandler(pages.Home()),
-	))
+	e.GET("/", func(c echo.Context) error {
+		handler := newHandler(pages.Home(s.exec.Active(), s.exec.Pending()))
+		handler.ServeHTTP(c.Response(), c.Request())
+		return nil
+	})
 
 	e.GET("/about", func(c echo.Context) error {
 		return c.NoContent(http.StatusNotFound)
@@ -130,7 +349,12 @@ func (s *Server) Start() error {
 		}
 		screen = config.ConfStatus.Screens[sId]
 
-		handler := newHandler(pages.ActionGroupScreen(screen))
+		var installed map[string]bool
+		if s.Manage {
+			installed = s.exec.Installed(c.Request().Context(), screen.Actions)
+		}
+
+		handler := newHandler(pages.ActionGroupScreen(screen, installed))
 		handler.ServeHTTP(c.Response(), c.Request())
 
 		return nil
@@ -150,26 +374,8 @@ func (s *Server) Start() error {
 			return err
 		}
 
-		// Step 3: Extract selected script IDs (those marked as "true")
-		scriptIds := make([]string, 0, len(scriptIdsStrs))
-		for id, state := range scriptIdsStrs {
-			if state == "true" {
-				scriptIds = append(scriptIds, id)
-			}
-		}
-
-		// Step 4: Sort and remove duplicates
-		slices.Sort(scriptIds)
-		scriptIds = slices.Compact(scriptIds)
-
-		// Step 5: Get actions corresponding to the selected script IDs
-		actions, _ := config.ConfStatus.GetActionsByIds(scriptIds)
-
-		// Step 6: Render the confirmation page with selected actions
-		handler := newHandler(pages.ConfirmChanges(actions))
-		handler.ServeHTTP(c.Response(), c.Request())
-
-		return nil
+		// Step 3: Render the confirmation page with the changes
+		return s.confirmChanges(c, scriptIdsStrs)
 	})
 
 	// Update POST handler for confirm_changes
@@ -181,7 +387,7 @@ func (s *Server) Start() error {
 		if formValue != "" {
 			if err := json.Unmarshal([]byte(formValue), &scriptIdsStrs); err == nil {
 				// Successfully got data from form submission
-				log.Println("Using script IDs from form data")
+				log.Debug("Using script IDs from form data")
 			}
 		}
 
@@ -191,12 +397,12 @@ func (s *Server) Start() error {
 			if cookieErr == nil && scriptIdsCookie.Value != "" {
 				if err := json.Unmarshal([]byte(scriptIdsCookie.Value), &scriptIdsStrs); err == nil {
 					// Successfully got data from cookie
-					log.Println("Using script IDs from cookie")
+					log.Debug("Using script IDs from cookie")
 				} else {
-					log.Printf("Error parsing cookie value: %v", err)
+					log.Debugf("Error parsing cookie value: %v", err)
 				}
 			} else {
-				log.Printf("Cookie error or empty: %v", cookieErr)
+				log.Debugf("Cookie error or empty: %v", cookieErr)
 			}
 		}
 
@@ -205,73 +411,256 @@ func (s *Server) Start() error {
 			return c.String(http.StatusBadRequest, "No script IDs found in form data or cookies")
 		}
 
-		// Continue with the existing logic
-		scriptIds := make([]string, 0, len(scriptIdsStrs))
-		for id, state := range scriptIdsStrs {
-			if state == "true" {
-				scriptIds = append(scriptIds, id)
+		return s.confirmChanges(c, scriptIdsStrs)
+	})
+
+	e.POST("/_/apply_changes", func(c echo.Context) error {
+		// Get script IDs from the request payload
+		type Payload struct {
+			ScriptIds []string `form:"script_ids"`
+			RemoveIds []string `form:"remove_ids"`
+			DryRun    bool     `form:"dry_run"`
+		}
+
+		payload := Payload{}
+		if err := c.Bind(&payload); err != nil {
+			log.Debugf("Failed to bind payload: %v", err)
+			return c.String(http.StatusBadRequest, "Invalid request format")
+		}
+
+		// In manage mode, only removing actions is fine too
+		var actions, removals []config.Action
+		if s.Manage && len(payload.RemoveIds) > 0 {
+			removals, _ = config.ConfStatus.GetActionsByIds(payload.RemoveIds)
+		}
+		if len(removals) == 0 || len(payload.ScriptIds) > 0 {
+			var err error
+			if actions, err = selectedActions(payload.ScriptIds); err != nil {
+				return err
 			}
 		}
 
-		slices.Sort(scriptIds)
-		scriptIds = slices.Compact(scriptIds)
+		// Only show what would be done
+		if payload.DryRun || s.DryRun {
+			plan := s.exec.PlanChange(c.Request().Context(), actions, removals)
+			handler := newHandler(pages.Plan(plan, payload.ScriptIds, payload.RemoveIds, s.DryRun))
+			handler.ServeHTTP(c.Response(), c.Request())
+			return nil
+		}
+
+		// The run keeps going on its own, follow it on its page
+		run, err := s.exec.Change(actions, removals)
+		if err != nil {
+			return startError(err)
+		}
+
+		s.follow(run)
+
+		return c.Redirect(http.StatusSeeOther, "/runs/"+run.ID)
+	})
+
+	// The plan of a run of the given actions, for tools and config authors.
+	// Nothing is executed besides the check scripts.
+	e.GET("/_/plan", func(c echo.Context) error {
+		actions, err := selectedActions(c.QueryParams()["script_ids"])
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, s.exec.Plan(c.Request().Context(), actions))
+	})
+
+	// The user is done with the setup, don't show it again on next launch
+	e.POST("/_/complete", func(c echo.Context) error {
+		s.markCompleted()
+		return c.Redirect(http.StatusSeeOther, "/")
+	})
 
-		actions, _ := config.ConfStatus.GetActionsByIds(scriptIds)
+	// Unfinished runs of a previous session, from a crash or a reboot
+	e.POST("/_/pending/:id/resume", func(c echo.Context) error {
+		p, ok := s.pending(c.Param("id"))
+		if !ok {
+			return echo.NewHTTPError(http.StatusNotFound, "Run not found")
+		}
+		run, err := s.Resume(p)
+		if err != nil {
+			return startError(err)
+		}
+		return c.Redirect(http.StatusSeeOther, "/runs/"+run.ID)
+	})
 
-		handler := newHandler(pages.ConfirmChanges(actions))
+	e.POST("/_/pending/:id/discard", func(c echo.Context) error {
+		p, ok := s.pending(c.Param("id"))
+		if !ok {
+			return echo.NewHTTPError(http.StatusNotFound, "Run not found")
+		}
+		if err := s.exec.Journal.Discard(p.RunID); err != nil {
+			log.Errorf("Failed to discard run: %v", err)
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to discard run")
+		}
+		return c.Redirect(http.StatusSeeOther, "/")
+	})
+
+	e.POST("/_/reboot", func(c echo.Context) error {
+		if s.exec.Busy() {
+			return c.String(http.StatusConflict, "A run is still in progress")
+		}
+		if err := exec.Command("systemctl", "reboot").Run(); err != nil {
+			log.Errorf("Failed to reboot: %v", err)
+			return c.String(http.StatusInternalServerError, "Failed to reboot, please reboot manually")
+		}
+		return c.NoContent(http.StatusAccepted)
+	})
+
+	e.GET("/history", func(c echo.Context) error {
+		var records []history.Record
+		if s.exec.History != nil {
+			var err error
+			if records, err = s.exec.History.List(); err != nil {
+				log.Errorf("Failed to read history: %v", err)
+				return echo.NewHTTPError(http.StatusInternalServerError, "Failed to read history")
+			}
+		}
+
+		handler := newHandler(pages.History(records))
 		handler.ServeHTTP(c.Response(), c.Request())
+		return nil
+	})
+
+	e.GET("/history/:id", func(c echo.Context) error {
+		rec, err := s.historyRecord(c.Param("id"))
+		if err != nil {
+			return err
+		}
 
+		handler := newHandler(pages.Transcript(rec))
+		handler.ServeHTTP(c.Response(), c.Request())
 		return nil
 	})
 
-	e.POST("/_/apply_changes", func(c echo.Context) error {
-		// Disable heartbeat while scripts are running
-		config.Inhibit.Store(true)
+	e.GET("/history/:id/transcript", func(c echo.Context) error {
+		rec, err := s.historyRecord(c.Param("id"))
+		if err != nil {
+			return err
+		}
 
-		// Get script IDs from the request payload
-		type Payload struct {
-			ScriptIds []string `form:"script_ids"`
+		c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="yafti-`+rec.ID+`.txt"`)
+		c.Response().Header().Set(echo.HeaderContentType, echo.MIMETextPlainCharsetUTF8)
+		c.Response().WriteHeader(http.StatusOK)
+		return rec.WriteTranscript(c.Response())
+	})
+
+	e.POST("/_/history/:id/rerun", func(c echo.Context) error {
+		rec, err := s.historyRecord(c.Param("id"))
+		if err != nil {
+			return err
+		}
+		if actions, _ := rec.Failed(); len(actions) == 0 {
+			return c.String(http.StatusBadRequest, "No failed actions to run again")
 		}
 
-		payload := Payload{}
-		if err := c.Bind(&payload); err != nil {
-			log.Printf("Failed to bind payload: %v", err)
-			return c.String(http.StatusBadRequest, "Invalid request format")
+		run, err := s.exec.Retry(rec)
+		if err != nil {
+			return startError(err)
 		}
+		s.follow(run)
 
-		if len(payload.ScriptIds) == 0 {
-			log.Printf("No script IDs provided in request")
-			return c.String(http.StatusBadRequest, "No script IDs provided")
+		return c.Redirect(http.StatusSeeOther, "/runs/"+run.ID)
+	})
+
+	e.POST("/_/history/:id/revert", func(c echo.Context) error {
+		rec, err := s.historyRecord(c.Param("id"))
+		if err != nil {
+			return err
+		}
+		i := slices.IndexFunc(rec.Actions, func(a history.ActionRecord) bool {
+			return a.Action.ID == c.FormValue("action")
+		})
+		if i < 0 || !rec.Actions[i].Revertable() {
+			return c.String(http.StatusBadRequest, "No completed action to revert")
 		}
 
-		// Get actions corresponding to the script IDs
-		actions, found := config.ConfStatus.GetActionsByIds(payload.ScriptIds)
-		if !found || len(actions) == 0 {
-			log.Printf("No actions found for the provided script IDs")
-			return c.String(http.StatusBadRequest, "No actions found for the provided script IDs")
+		run, err := s.exec.Revert([]config.Action{rec.Actions[i].Action})
+		if err != nil {
+			return startError(err)
 		}
+		s.follow(run)
 
-		// Extract script commands from the actions
-		cmds := make([]string, 0, len(actions))
-		for _, action := range actions {
-			if action.Script != "" {
-				cmds = append(cmds, action.Script)
+		return c.Redirect(http.StatusSeeOther, "/runs/"+run.ID)
+	})
+
+	e.GET("/runs/:id", func(c echo.Context) error {
+		run, ok := s.exec.Run(c.Param("id"))
+		if !ok {
+			// Runs of previous sessions are only left in the history
+			if _, err := s.historyRecord(c.Param("id")); err != nil {
+				return err
 			}
+			return c.Redirect(http.StatusSeeOther, "/history/"+c.Param("id"))
+		}
+
+		handler := newHandler(pages.ApplyChanges(run))
+		handler.ServeHTTP(c.Response(), c.Request())
+
+		return nil
+	})
+
+	// Answers a run asking whether to retry, skip or abort after a failure
+	e.POST("/_/runs/:id/decision", func(c echo.Context) error {
+		run, ok := s.exec.Run(c.Param("id"))
+		if !ok {
+			return echo.NewHTTPError(http.StatusNotFound, "Run not found")
+		}
+		idx, err := strconv.Atoi(c.FormValue("index"))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid action index")
 		}
 
-		if len(cmds) == 0 {
-			log.Printf("No scripts found in the selected actions")
-			return c.String(http.StatusBadRequest, "Selected actions contain no scripts to execute")
+		err = run.Decide(idx, executor.Decision(c.FormValue("decision")))
+		switch {
+		case errors.Is(err, executor.ErrNoDecision):
+			return echo.NewHTTPError(http.StatusConflict, err.Error())
+		case err != nil:
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 		}
+		return c.NoContent(http.StatusNoContent)
+	})
 
-		handler := newHandler(pages.ApplyChanges(cmds))
+	e.GET("/_/runs/:id/terminals/:n", s.terminalHandler)
 
-		handler.ServeHTTP(c.Response(), c.Request())
+	// Starts a new run with only the actions that did not succeed
+	e.POST("/_/runs/:id/retry", func(c echo.Context) error {
+		run, ok := s.exec.Run(c.Param("id"))
+		if !ok {
+			return echo.NewHTTPError(http.StatusNotFound, "Run not found")
+		}
+		rec := run.Record()
+		if actions, _ := rec.Failed(); len(actions) == 0 {
+			return c.String(http.StatusBadRequest, "No failed actions to run again")
+		}
 
-		// Enable heartbeat after handler finishes processing the request
-		config.Inhibit.Store(false)
+		retry, err := s.exec.Retry(rec)
+		if err != nil {
+			return startError(err)
+		}
+		s.follow(retry)
 
-		return nil
+		return c.Redirect(http.StatusSeeOther, "/runs/"+retry.ID)
+	})
+
+	e.GET("/_/runs/:id/diagnostics", func(c echo.Context) error {
+		var rec history.Record
+		if run, ok := s.exec.Run(c.Param("id")); ok {
+			rec = run.Record()
+		} else {
+			var err error
+			if rec, err = s.historyRecord(c.Param("id")); err != nil {
+				return err
+			}
+		}
+
+		c.Response().Header().Set(echo.HeaderContentType, echo.MIMETextPlainCharsetUTF8)
+		c.Response().WriteHeader(http.StatusOK)
+		return s.writeDiagnostics(c.Response(), rec)
 	})
 
 	e.POST("/_/post_test", func(c echo.Context) error {
@@ -298,7 +687,19 @@ func (s *Server) Start() error {
 	})
 
 	// Start server
-	go s.monitorHeartbeat()
-	log.Printf("Server started at http://localhost:%s", consts.PORT)
-	return s.e.Start("127.0.0.1:" + consts.PORT)
+	s.m.Lock()
+	s.updateIdleLocked()
+	s.m.Unlock()
+	if s.socket != nil {
+		log.Infof("Listening on unix socket %s", s.SocketPath)
+		go func() {
+			if err := s.socketSrv.Serve(s.socket); err != nil && err != http.ErrServerClosed {
+				log.Errorf("Unix socket error: %v", err)
+			}
+		}()
+		defer os.Remove(s.SocketPath)
+	}
+	log.Infof("Server started at %s", s.URL())
+	e.Listener = s.listener
+	return e.Start(s.Addr)
 }
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/net/websocket"
+)
+
+// terminalMessage is what the page showing a terminal sends over its
+// WebSocket: what the user typed, or the new size of the terminal.
+type terminalMessage struct {
+	Type string `json:"type"` // "input" or "resize"
+	Data string `json:"data"`
+	Cols int    `json:"cols"`
+	Rows int    `json:"rows"`
+}
+
+// terminalHandler bridges the terminal of an interactive action with the
+// page showing it, see [executor.Run.Terminal]. The output of the script
+// is sent as binary messages, from the start.
+func (s *Server) terminalHandler(c echo.Context) error {
+	run, ok := s.exec.Run(c.Param("id"))
+	if !ok {
+		return echo.NewHTTPError(http.StatusNotFound, "Run not found")
+	}
+	n, err := strconv.Atoi(c.Param("n"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid terminal number")
+	}
+	t, ok := run.Terminal(n)
+	if !ok {
+		return echo.NewHTTPError(http.StatusNotFound, "Terminal not found")
+	}
+
+	ws := websocket.Server{
+		Handshake: sameOrigin,
+		Handler: func(conn *websocket.Conn) {
+			conn.PayloadType = websocket.BinaryFrame
+			ctx, cancel := context.WithCancel(c.Request().Context())
+			defer cancel()
+
+			go func() {
+				defer cancel()
+				for {
+					var msg terminalMessage
+					if err := websocket.JSON.Receive(conn, &msg); err != nil {
+						return
+					}
+					// The script may be gone already, nothing to tell the user
+					switch msg.Type {
+					case "input":
+						t.Write([]byte(msg.Data))
+					case "resize":
+						t.Resize(msg.Cols, msg.Rows)
+					}
+				}
+			}()
+
+			for p := range t.Output(ctx) {
+				if _, err := conn.Write(p); err != nil {
+					return
+				}
+			}
+		},
+	}
+	ws.ServeHTTP(c.Response(), c.Request())
+	return nil
+}
+
+// sameOrigin only accepts WebSocket connections from the pages of yafti,
+// so other sites open in the browser can't type into scripts.
+func sameOrigin(config *websocket.Config, req *http.Request) error {
+	var err error
+	config.Origin, err = websocket.Origin(config, req)
+	if err != nil {
+		return err
+	}
+	if config.Origin == nil || config.Origin.Host != req.Host {
+		return errors.New("cross-origin WebSocket connection")
+	}
+	return nil
+}
+
+// socketClientKey marks the context of requests received on the Unix
+// socket, which browsers can't reach.
+type socketClientKey struct{}
+
+// sameOriginRequests is the middleware refusing requests changing anything
+// unless sent by the pages of yafti, so other sites open in the browser
+// can't start runs, see [sameOrigin]. Clients of the Unix socket are local
+// programs and always allowed.
+func sameOriginRequests(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := c.Request()
+		switch req.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			return next(c)
+		}
+		if req.Context().Value(socketClientKey{}) != nil {
+			return next(c)
+		}
+		origin, err := url.Parse(req.Header.Get(echo.HeaderOrigin))
+		if err != nil || origin.Host == "" || origin.Host != req.Host {
+			return echo.NewHTTPError(http.StatusForbidden, "Cross-origin request")
+		}
+		return next(c)
+	}
+}
@@ -0,0 +1,200 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Zeglius/yafti-go/executor"
+	"golang.org/x/term"
+)
+
+const (
+	styleReset   = "\x1b[0m"
+	styleBold    = "\x1b[1m"
+	styleDim     = "\x1b[2m"
+	styleReverse = "\x1b[7m"
+	styleViolet  = "\x1b[35m"
+	styleGreen   = "\x1b[32m"
+	styleRed     = "\x1b[31m"
+	styleYellow  = "\x1b[33m"
+)
+
+// draw renders the whole UI from scratch.
+func (u *ui) draw() {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width <= 0 || height <= 0 {
+		width, height = 80, 24
+	}
+
+	var lines []string
+	switch u.view {
+	case viewScreen:
+		lines = u.drawScreen()
+	case viewReview:
+		lines = u.drawReview()
+	case viewRun:
+		lines = u.drawRun(height)
+	}
+
+	var b strings.Builder
+	b.WriteString("\x1b[H\x1b[2J")
+	for i, line := range lines {
+		if i >= height {
+			break
+		}
+		b.WriteString(truncate(line, width))
+		b.WriteString(styleReset + "\r\n")
+	}
+	fmt.Fprint(u.out, b.String())
+}
+
+func (u *ui) drawScreen() []string {
+	screen := u.conf.Screens[u.screen]
+	lines := []string{
+		fmt.Sprintf("%s%s%s  %s(%d/%d)", styleBold, screen.Title, styleReset, styleDim, u.screen+1, len(u.conf.Screens)),
+	}
+	if screen.Description != "" {
+		lines = append(lines, styleDim+screen.Description)
+	}
+	lines = append(lines, "")
+
+	for i, act := range screen.Actions {
+		box := "[ ]"
+		if u.selected[act.ID] {
+			box = styleViolet + "[x]" + styleReset
+		}
+		line := fmt.Sprintf(" %s %s", box, act.Title)
+		if i == u.cursor[u.screen] {
+			line = styleReverse + ">" + styleReset + line
+		} else {
+			line = " " + line
+		}
+		lines = append(lines, line)
+		if act.Description != "" {
+			lines = append(lines, "       "+styleDim+act.Description)
+		}
+	}
+
+	lines = append(lines, "",
+		styleDim+"↑/↓ move • space toggle • →/enter next • ← back • q quit",
+	)
+	return lines
+}
+
+func (u *ui) drawReview() []string {
+	lines := []string{
+		styleBold + "Confirm Your Selections",
+		styleDim + "Review the following items before installation",
+		"",
+	}
+
+	actions := u.selectedActions()
+	if len(actions) == 0 {
+		lines = append(lines, styleYellow+"No actions selected.")
+	}
+	for _, act := range actions {
+		lines = append(lines, " "+styleViolet+"✓"+styleReset+" "+act.Title)
+	}
+
+	lines = append(lines, "",
+		styleDim+"enter install • ← back • q quit",
+	)
+	return lines
+}
+
+func (u *ui) drawRun(height int) []string {
+	title := "Installing Selected Items"
+	if u.finished {
+		title = "Installation finished"
+	}
+	lines := []string{styleBold + title, ""}
+
+	results := u.run.Results()
+	for i, act := range u.run.Actions {
+		var status string
+		switch u.statuses[i] {
+		case executor.StatusRunning:
+			status = styleViolet + "[…]"
+		case executor.StatusSuccess:
+			status = styleGreen + "[✓]"
+		case executor.StatusFailed:
+			status = styleRed + "[✗]"
+		case executor.StatusInterrupted:
+			status = styleYellow + "[!]"
+		case executor.StatusReverted:
+			status = styleDim + "[↺]"
+		case executor.StatusNotRun:
+			status = styleYellow + "[~]"
+		case executor.StatusSkipped:
+			status = styleDim + "[-]"
+		default:
+			status = styleDim + "[ ]"
+		}
+		line := " " + status + styleReset + " " + act.Title
+		if res := results[i]; u.statuses[i] == executor.StatusRunning {
+			if res.Progress > 0 {
+				line += fmt.Sprintf(" %s%3d%%", styleViolet, res.Progress)
+			}
+			if res.Message != "" {
+				line += " " + styleDim + res.Message
+			}
+		}
+		lines = append(lines, line)
+	}
+	lines = append(lines, "", styleDim+strings.Repeat("─", 40))
+
+	footer := styleDim + "ctrl+c abort"
+	if u.finished {
+		footer = styleDim + "enter/q quit"
+	} else if len(u.asking) > 0 {
+		footer = styleYellow + u.run.Actions[u.asking[0]].Title + " failed: r retry, s skip, a abort the rest"
+	}
+
+	// Fill the remaining space with the tail of the log
+	room := height - len(lines) - 2
+	logLines := u.logLines
+	if room < 0 {
+		room = 0
+	}
+	if len(logLines) > room {
+		logLines = logLines[len(logLines)-room:]
+	}
+	lines = append(lines, logLines...)
+	for len(lines) < height-1 {
+		lines = append(lines, "")
+	}
+	lines = append(lines, footer)
+
+	return lines
+}
+
+// truncate cuts s so it fits in width columns, ignoring escape sequences.
+func truncate(s string, width int) string {
+	var b strings.Builder
+	cols := 0
+	inEscape := false
+	for _, r := range s {
+		switch {
+		case r == '\x1b':
+			inEscape = true
+		case inEscape:
+			if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') {
+				inEscape = false
+			}
+		case r == '\t':
+			r = ' '
+			fallthrough
+		default:
+			if r < ' ' {
+				continue
+			}
+			if cols >= width {
+				return b.String()
+			}
+			cols++
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
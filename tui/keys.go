@@ -0,0 +1,105 @@
+package tui
+
+import (
+	"io"
+)
+
+type key int
+
+const (
+	keyUnknown key = iota
+	keyUp
+	keyDown
+	keyLeft
+	keyRight
+	keyEnter
+	keySpace
+	keyTab
+	keyBackTab
+	keyBackspace
+	keyQuit
+	keyCtrlC
+	keyRetry
+	keySkip
+	keyAbort
+)
+
+// readInput reads what is typed on a terminal in raw mode, decoded into
+// keys with [parseKeys] unless it goes to the terminal of a script.
+// The returned channel is closed once r reaches EOF.
+func readInput(r io.Reader) <-chan []byte {
+	input := make(chan []byte)
+
+	go func() {
+		defer close(input)
+
+		for {
+			buf := make([]byte, 64)
+			n, err := r.Read(buf)
+			if n > 0 {
+				input <- buf[:n]
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	return input
+}
+
+func parseKeys(b []byte) []key {
+	var keys []key
+	for i := 0; i < len(b); i++ {
+		switch c := b[i]; c {
+		case 0x1b:
+			// CSI sequences: ESC [ <final byte>
+			if i+2 < len(b) && b[i+1] == '[' {
+				i += 2
+				switch b[i] {
+				case 'A':
+					keys = append(keys, keyUp)
+				case 'B':
+					keys = append(keys, keyDown)
+				case 'C':
+					keys = append(keys, keyRight)
+				case 'D':
+					keys = append(keys, keyLeft)
+				case 'Z':
+					keys = append(keys, keyBackTab)
+				}
+				continue
+			}
+			keys = append(keys, keyQuit)
+		case '\r', '\n':
+			keys = append(keys, keyEnter)
+		case ' ':
+			keys = append(keys, keySpace)
+		case '\t':
+			keys = append(keys, keyTab)
+		case 0x7f, 0x08:
+			keys = append(keys, keyBackspace)
+		case 0x03:
+			keys = append(keys, keyCtrlC)
+		case 'q':
+			keys = append(keys, keyQuit)
+		case 'k':
+			keys = append(keys, keyUp)
+		case 'j':
+			keys = append(keys, keyDown)
+		case 'h':
+			keys = append(keys, keyLeft)
+		case 'l':
+			keys = append(keys, keyRight)
+		case 'r':
+			keys = append(keys, keyRetry)
+		case 's':
+			keys = append(keys, keySkip)
+		case 'a':
+			keys = append(keys, keyAbort)
+		default:
+			keys = append(keys, keyUnknown)
+		}
+	}
+	return keys
+}
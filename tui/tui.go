@@ -0,0 +1,378 @@
+// Package tui implements a terminal frontend for yafti, for when no
+// browser is available (e.g. on a TTY).
+//
+// It walks through the same screens as the web interface, and runs the
+// selected actions with the same [executor.Executor].
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/Zeglius/yafti-go/config"
+	"github.com/Zeglius/yafti-go/executor"
+	"golang.org/x/term"
+)
+
+// Maximum number of output lines kept for the log pane.
+const maxLogLines = 1000
+
+type view int
+
+const (
+	viewScreen view = iota
+	viewReview
+	viewRun
+)
+
+type ui struct {
+	conf *config.Config
+	exec *executor.Executor
+	out  *os.File
+
+	view     view
+	screen   int         // Index of the current screen
+	cursor   map[int]int // Cursor position per screen
+	selected map[string]bool
+
+	run       *executor.Run
+	statuses  []executor.Status
+	logLines  []string
+	logAction int   // Action the last log line belongs to
+	asking    []int // Actions waiting for a decision, oldest first
+	finished  bool
+	// Terminals of the interactive scripts, shown one at a time in place
+	// of the UI, oldest first
+	terminals []shownTerminal
+	err       error // Returned by Run once the UI quits
+}
+
+// Run starts the terminal UI on stdin/stdout, and returns once the user quits.
+func Run(conf *config.Config, exec *executor.Executor) error {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return errors.New("stdin is not a terminal")
+	}
+	if len(conf.Screens) == 0 {
+		return errors.New("no screens found in configuration")
+	}
+
+	oldState, err := term.MakeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		return err
+	}
+	defer term.Restore(int(os.Stdin.Fd()), oldState)
+
+	// Use the alternate screen buffer and hide the cursor
+	fmt.Fprint(os.Stdout, "\x1b[?1049h\x1b[?25l")
+	defer fmt.Fprint(os.Stdout, "\x1b[?25h\x1b[?1049l")
+
+	u := &ui{
+		conf:     conf,
+		exec:     exec,
+		out:      os.Stdout,
+		cursor:   make(map[int]int),
+		selected: make(map[string]bool),
+	}
+	for act := range conf.GetAllActions() {
+		u.selected[act.ID] = act.Default
+	}
+
+	if err := u.loop(); err != nil {
+		return err
+	}
+	return u.err
+}
+
+// shownTerminal is the terminal of an interactive script, see [ui.attach].
+type shownTerminal struct {
+	t      *executor.Terminal
+	action int
+	copied chan struct{} // Closed once all its output was written
+}
+
+func (u *ui) loop() error {
+	input := readInput(os.Stdin)
+	winch := make(chan os.Signal, 1)
+	signal.Notify(winch, syscall.SIGWINCH)
+	defer signal.Stop(winch)
+
+	var events <-chan executor.Event
+	subscribed := false
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for {
+		// The script of an interactive action has the screen
+		var scriptDone <-chan struct{}
+		if len(u.terminals) > 0 {
+			scriptDone = u.terminals[0].t.Done()
+		} else {
+			u.draw()
+		}
+
+		select {
+		case b, ok := <-input:
+			if !ok {
+				return nil
+			}
+			if len(u.terminals) > 0 {
+				u.terminals[0].t.Write(b)
+				continue
+			}
+			for _, k := range parseKeys(b) {
+				if quit := u.handleKey(k); quit {
+					if u.run != nil && !u.finished {
+						u.run.Cancel()
+						<-u.run.Done()
+					}
+					return nil
+				}
+			}
+			// Subscribed once, a second subscription would replay the run
+			if u.run != nil && !subscribed {
+				events = u.run.Events(ctx)
+				subscribed = true
+			}
+		case <-winch:
+			if len(u.terminals) > 0 {
+				u.resizeTerminal(u.terminals[0].t)
+			}
+		case <-scriptDone:
+			u.detach()
+		case ev, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			u.handleEvent(ev)
+			if ev.Kind == executor.TerminalOpened {
+				if t, ok := u.run.Terminal(ev.Terminal); ok {
+					u.terminals = append(u.terminals, shownTerminal{t: t, action: ev.Index})
+					if len(u.terminals) == 1 {
+						u.attach()
+					}
+				}
+			}
+		}
+	}
+}
+
+// attach gives the screen to the first terminal of u.terminals: the UI is
+// put aside, and what the user types goes to the script until it exits.
+func (u *ui) attach() {
+	st := &u.terminals[0]
+	fmt.Fprint(u.out, "\x1b[?25h\x1b[?1049l")
+	fmt.Fprintf(u.out, "==> %s\r\n", u.run.Actions[st.action].Title)
+	u.resizeTerminal(st.t)
+
+	st.copied = make(chan struct{})
+	go func(t *executor.Terminal, copied chan struct{}) {
+		defer close(copied)
+		for p := range t.Output(context.Background()) {
+			u.out.Write(p)
+		}
+	}(st.t, st.copied)
+}
+
+// detach brings the UI back once the script of the first terminal of
+// u.terminals exited, then shows the next one, if any.
+func (u *ui) detach() {
+	<-u.terminals[0].copied
+	fmt.Fprint(u.out, "\x1b[?1049h\x1b[?25l")
+	u.terminals = u.terminals[1:]
+	if len(u.terminals) > 0 {
+		u.attach()
+	}
+}
+
+// resizeTerminal makes t the size of the terminal of the UI.
+func (u *ui) resizeTerminal(t *executor.Terminal) {
+	if width, height, err := term.GetSize(int(u.out.Fd())); err == nil {
+		t.Resize(width, height)
+	}
+}
+
+// handleKey updates the state of the UI after a key press.
+// It returns true when the user wants to quit.
+func (u *ui) handleKey(k key) bool {
+	if k == keyCtrlC {
+		return true
+	}
+
+	switch u.view {
+	case viewScreen:
+		actions := u.conf.Screens[u.screen].Actions
+		cur := u.cursor[u.screen]
+		switch k {
+		case keyUp:
+			if cur > 0 {
+				u.cursor[u.screen] = cur - 1
+			}
+		case keyDown:
+			if cur < len(actions)-1 {
+				u.cursor[u.screen] = cur + 1
+			}
+		case keySpace:
+			if len(actions) > 0 {
+				id := actions[cur].ID
+				u.selected[id] = !u.selected[id]
+			}
+		case keyRight, keyEnter, keyTab:
+			if u.screen < len(u.conf.Screens)-1 {
+				u.screen++
+			} else {
+				u.view = viewReview
+			}
+		case keyLeft, keyBackTab:
+			if u.screen > 0 {
+				u.screen--
+			}
+		case keyQuit:
+			return true
+		}
+
+	case viewReview:
+		switch k {
+		case keyLeft, keyBackTab, keyBackspace:
+			u.view = viewScreen
+		case keyEnter:
+			if actions := u.selectedActions(); len(actions) > 0 {
+				if err := u.startRun(actions); err != nil {
+					u.err = err
+					return true
+				}
+			}
+		case keyQuit:
+			return true
+		}
+
+	case viewRun:
+		if u.finished && (k == keyQuit || k == keyEnter) {
+			return true
+		}
+		if len(u.asking) > 0 {
+			var d executor.Decision
+			switch k {
+			case keyRetry:
+				d = executor.DecisionRetry
+			case keySkip:
+				d = executor.DecisionSkip
+			case keyAbort:
+				d = executor.DecisionAbort
+			default:
+				return false
+			}
+			if err := u.run.Decide(u.asking[0], d); err != nil {
+				u.appendLog("Error: " + err.Error())
+			}
+		}
+	}
+
+	return false
+}
+
+func (u *ui) handleEvent(ev executor.Event) {
+	// Parallel actions interleave their output, tell whose it is
+	switch ev.Kind {
+	case executor.ActionOutput, executor.ActionWarning, executor.ActionLink, executor.UndoStarted:
+		if ev.Index != u.logAction {
+			u.appendLog("── " + u.run.Actions[ev.Index].Title)
+		}
+	}
+	if ev.Kind != executor.RunFinished {
+		u.logAction = ev.Index
+	}
+
+	switch ev.Kind {
+	case executor.ActionStarted:
+		u.statuses[ev.Index] = executor.StatusRunning
+		u.appendLog("$ " + strings.Trim(u.run.Actions[ev.Index].Script, "\n\r\t"))
+	case executor.ActionOutput:
+		u.appendLog(ev.Text)
+	case executor.ActionWarning:
+		u.appendLog("Warning: " + ev.Text)
+	case executor.ActionLink:
+		u.appendLog(ev.Text + ": " + ev.URL)
+	case executor.ActionFinished:
+		u.statuses[ev.Index] = ev.Status
+		if ev.Status == executor.StatusNotRun {
+			u.appendLog("Not run: " + ev.Text)
+		} else if ev.Failed() {
+			u.appendLog(fmt.Sprintf("Command %s (exit code %d) %s", ev.Status, ev.ExitCode, ev.Text))
+		}
+	case executor.UndoStarted:
+		u.appendLog("$ " + strings.Trim(u.run.Actions[ev.Index].Undo, "\n\r\t"))
+	case executor.UndoFinished:
+		if ev.Status == executor.StatusReverted {
+			u.statuses[ev.Index] = ev.Status
+			u.appendLog("Reverted")
+		} else {
+			u.appendLog(fmt.Sprintf("Revert failed (exit code %d) %s", ev.ExitCode, ev.Text))
+		}
+	case executor.DecisionRequired:
+		u.asking = append(u.asking, ev.Index)
+	case executor.DecisionMade:
+		u.asking = slices.DeleteFunc(u.asking, func(i int) bool { return i == ev.Index })
+	case executor.RebootRequired:
+		if ev.Index < len(u.run.Actions)-1 {
+			u.appendLog("Reboot required: the remaining actions continue on the next login")
+		} else {
+			u.appendLog("Reboot required to finish")
+		}
+	case executor.RunFinished:
+		u.finished = true
+		if u.run.Succeeded() && u.exec.State != nil {
+			if err := u.exec.State.MarkCompleted(u.conf.Hash()); err != nil {
+				u.appendLog("Failed to save state: " + err.Error())
+			}
+		}
+	}
+}
+
+// selectedActions returns the actions toggled on, in config order, which
+// have a script to execute.
+func (u *ui) selectedActions() []config.Action {
+	ids := make([]string, 0, len(u.selected))
+	for id, on := range u.selected {
+		if on {
+			ids = append(ids, id)
+		}
+	}
+
+	actions, _ := u.conf.GetActionsByIds(ids)
+	res := actions[:0]
+	for _, a := range actions {
+		if a.Script != "" {
+			res = append(res, a)
+		}
+	}
+	return res
+}
+
+func (u *ui) startRun(actions []config.Action) error {
+	run, err := u.exec.Start(actions)
+	if err != nil {
+		return err
+	}
+	u.run = run
+	u.logAction = -1
+	u.statuses = make([]executor.Status, len(actions))
+	for i := range u.statuses {
+		u.statuses[i] = executor.StatusPending
+	}
+	u.view = viewRun
+	return nil
+}
+
+func (u *ui) appendLog(line string) {
+	u.logLines = append(u.logLines, line)
+	if len(u.logLines) > maxLogLines {
+		u.logLines = u.logLines[len(u.logLines)-maxLogLines:]
+	}
+}
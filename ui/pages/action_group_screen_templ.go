@@ -16,7 +16,9 @@ import (
 // ActionGroupScreen displays a list of actions with toggles.
 //
 // One is assigned per each element at `screens.[]` in the config file.
-func ActionGroupScreen(screen config.Screen) templ.Component {
+// In manage mode, installed tells which actions are installed, by ID, and
+// is nil otherwise.
+func ActionGroupScreen(screen config.Screen, installed map[string]bool) templ.Component {
 	return templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
 		templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
 		if templ_7745c5c3_CtxErr := ctx.Err(); templ_7745c5c3_CtxErr != nil {
@@ -56,23 +58,45 @@ func ActionGroupScreen(screen config.Screen) templ.Component {
 			var templ_7745c5c3_Var3 string
 			templ_7745c5c3_Var3, templ_7745c5c3_Err = templ.JoinStringErrs(screen.Title)
 			if templ_7745c5c3_Err != nil {
-				return templ.Error{Err: templ_7745c5c3_Err, FileName: `ui/pages/action_group_screen.templ`, Line: 15, Col: 54}
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `ui/pages/action_group_screen.templ`, Line: 17, Col: 54}
 			}
 			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var3))
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
-			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 2, "</h2><p class=\"text-gray-600\">Select the options you'd like to install</p></div><div class=\"bg-white rounded-lg shadow-md p-6\"><form id=\"actionForm\" method=\"POST\" action=\"/confirm_changes\" class=\"flex flex-col gap-4\"><input type=\"hidden\" id=\"scriptIdsInput\" name=\"scriptIds\" value=\"{}\"> ")
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 2, "</h2>")
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
-			for _, act := range screen.Actions {
-				templ_7745c5c3_Err = components.ActionToggle(act).Render(ctx, templ_7745c5c3_Buffer)
+			if installed != nil {
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 3, "<p class=\"text-gray-600\">Turn on what you want installed, and off what you want removed</p>")
+				if templ_7745c5c3_Err != nil {
+					return templ_7745c5c3_Err
+				}
+			} else {
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 4, "<p class=\"text-gray-600\">Select the options you'd like to install</p>")
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
 			}
-			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 3, "<div class=\"flex justify-between mt-6\"><a href=\"/\" class=\"btn btn-outline\">Back to Home</a> <button type=\"button\" class=\"btn btn-primary\" onclick=\"prepareAndSubmit()\">Continue</button></div></form></div></div><script>\n\t\t\tfunction prepareAndSubmit() {\n\t\t\t\t// Build a JSON object for selected actions\n\t\t\t\tconst selectedActions = {};\n\t\t\t\tdocument.querySelectorAll('input[type=\"checkbox\"][name=\"script_ids\"]').forEach(checkbox => {\n\t\t\t\t\tselectedActions[checkbox.value] = checkbox.checked ? 'true' : 'false';\n\t\t\t\t});\n\n\t\t\t\t// Set the cookie with the JSON data\n\t\t\t\tdocument.cookie = \"script_ids=\" + JSON.stringify(selectedActions) + \"; path=/; SameSite=Strict\";\n\t\t\t\t\n\t\t\t\t// Also include the data in the form submission\n\t\t\t\tdocument.getElementById('scriptIdsInput').value = JSON.stringify(selectedActions);\n\t\t\t\t\n\t\t\t\t// Submit the form\n\t\t\t\tdocument.getElementById('actionForm').submit();\n\t\t\t}\n\t\t</script>")
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 5, "</div><div class=\"bg-white rounded-lg shadow-md p-6\"><form id=\"actionForm\" method=\"POST\" action=\"/confirm_changes\" class=\"flex flex-col gap-4\"><input type=\"hidden\" id=\"scriptIdsInput\" name=\"scriptIds\" value=\"{}\"> ")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			for _, act := range screen.Actions {
+				if installed != nil {
+					templ_7745c5c3_Err = components.ManagedActionToggle(act, installed[act.ID]).Render(ctx, templ_7745c5c3_Buffer)
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+				} else {
+					templ_7745c5c3_Err = components.ActionToggle(act).Render(ctx, templ_7745c5c3_Buffer)
+					if templ_7745c5c3_Err != nil {
+						return templ_7745c5c3_Err
+					}
+				}
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 6, "<div class=\"flex justify-between mt-6\"><a href=\"/\" class=\"btn btn-outline\">Back to Home</a> <button type=\"button\" class=\"btn btn-primary\" onclick=\"prepareAndSubmit()\">Continue</button></div></form></div></div><script>\n\t\t\tfunction prepareAndSubmit() {\n\t\t\t\t// Build a JSON object for selected actions\n\t\t\t\tconst selectedActions = {};\n\t\t\t\tdocument.querySelectorAll('input[type=\"checkbox\"][name=\"script_ids\"]').forEach(checkbox => {\n\t\t\t\t\tselectedActions[checkbox.value] = checkbox.checked ? 'true' : 'false';\n\t\t\t\t});\n\n\t\t\t\t// Set the cookie with the JSON data\n\t\t\t\tdocument.cookie = \"script_ids=\" + JSON.stringify(selectedActions) + \"; path=/; SameSite=Strict\";\n\t\t\t\t\n\t\t\t\t// Also include the data in the form submission\n\t\t\t\tdocument.getElementById('scriptIdsInput').value = JSON.stringify(selectedActions);\n\t\t\t\t\n\t\t\t\t// Submit the form\n\t\t\t\tdocument.getElementById('actionForm').submit();\n\t\t\t}\n\t\t</script>")
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
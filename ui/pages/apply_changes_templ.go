@@ -9,11 +9,12 @@ import "github.com/a-h/templ"
 import templruntime "github.com/a-h/templ/runtime"
 
 import (
+	"github.com/Zeglius/yafti-go/executor"
 	"github.com/Zeglius/yafti-go/ui/components"
-	"strconv"
+	"strings"
 )
 
-func ApplyChanges(cmds []string) templ.Component {
+func ApplyChanges(run *executor.Run) templ.Component {
 	return templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
 		templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
 		if templ_7745c5c3_CtxErr := ctx.Err(); templ_7745c5c3_CtxErr != nil {
@@ -46,20 +47,19 @@ func ApplyChanges(cmds []string) templ.Component {
 				}()
 			}
 			ctx = templ.InitializeContext(ctx)
-			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 1, "<div class=\"container max-w-2xl mx-auto flex flex-col my-8\"><div class=\"mb-8\"><h2 class=\"text-3xl font-bold mb-2\">Installing Selected Items</h2><p class=\"text-gray-600\">Please wait while the selected items are being installed</p></div><div class=\"bg-white rounded-lg shadow-md p-6\"><div class=\"mb-4\"><div class=\"flex items-center mb-4\"><svg class=\"animate-spin -ml-1 mr-3 h-5 w-5 text-violet-700\" xmlns=\"http://www.w3.org/2000/svg\" fill=\"none\" viewBox=\"0 0 24 24\"><circle class=\"opacity-25\" cx=\"12\" cy=\"12\" r=\"10\" stroke=\"currentColor\" stroke-width=\"4\"></circle> <path class=\"opacity-75\" fill=\"currentColor\" d=\"M4 12a8 8 0 018-8V0C5.373 0 0 5.373 0 12h4zm2 5.291A7.962 7.962 0 014 12H0c0 3.042 1.135 5.824 3 7.938l3-2.647z\"></path></svg> <span class=\"font-medium\">Installation in progress...</span></div><div class=\"bg-gray-900 text-gray-100 p-4 rounded-md font-mono text-sm overflow-auto max-h-96\"><template shadowrootmode=\"open\">")
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 1, "<div class=\"container max-w-2xl mx-auto flex flex-col my-8\"><div class=\"mb-8\"><h2 class=\"text-3xl font-bold mb-2\">Installing Selected Items</h2><p class=\"text-gray-600\">Please wait while the selected items are being installed</p></div><div class=\"bg-white rounded-lg shadow-md p-6\"><div class=\"mb-4\"><div id=\"run-progress\" class=\"flex items-center mb-4\"><svg class=\"animate-spin -ml-1 mr-3 h-5 w-5 text-violet-700\" xmlns=\"http://www.w3.org/2000/svg\" fill=\"none\" viewBox=\"0 0 24 24\"><circle class=\"opacity-25\" cx=\"12\" cy=\"12\" r=\"10\" stroke=\"currentColor\" stroke-width=\"4\"></circle> <path class=\"opacity-75\" fill=\"currentColor\" d=\"M4 12a8 8 0 018-8V0C5.373 0 0 5.373 0 12h4zm2 5.291A7.962 7.962 0 014 12H0c0 3.042 1.135 5.824 3 7.938l3-2.647z\"></path></svg> <span class=\"font-medium\">Installation in progress...</span></div><link href=\"/static/css/xterm.css\" rel=\"stylesheet\" type=\"text/css\"><script src=\"/static/js/xterm.js\"></script><script src=\"/static/js/addon-fit.js\"></script><script>\n\t\t\t\t\t\t// Called by the events of components.CommandEvent\n\t\t\t\t\t\tfunction setActionProgress(id, value) {\n\t\t\t\t\t\t\tconst el = document.getElementById(id)\n\t\t\t\t\t\t\tel.classList.remove('hidden')\n\t\t\t\t\t\t\tel.querySelector('progress').value = value\n\t\t\t\t\t\t}\n\t\t\t\t\t\tfunction setActionStatus(id, text) {\n\t\t\t\t\t\t\tconst el = document.getElementById(id)\n\t\t\t\t\t\t\tel.classList.remove('hidden')\n\t\t\t\t\t\t\tel.querySelector('[data-status]').textContent = text\n\t\t\t\t\t\t}\n\t\t\t\t\t\tfunction removeElement(id) {\n\t\t\t\t\t\t\tdocument.getElementById(id)?.remove()\n\t\t\t\t\t\t}\n\t\t\t\t\t\t// Plain fetch, htmx only processes the page once it is fully streamed\n\t\t\t\t\t\tfunction decide(runID, index, decision) {\n\t\t\t\t\t\t\tconst body = new URLSearchParams({ index, decision })\n\t\t\t\t\t\t\tfetch(`/_/runs/${runID}/decision`, { method: 'POST', body })\n\t\t\t\t\t\t}\n\t\t\t\t\t\t// Shows the terminal of an interactive action with xterm.js, or as\n\t\t\t\t\t\t// plain text if it was not vendored with `just xterm`\n\t\t\t\t\t\tfunction openTerminal(id, url) {\n\t\t\t\t\t\t\tconst el = document.getElementById(id)\n\t\t\t\t\t\t\tif (typeof Terminal === 'undefined') {\n\t\t\t\t\t\t\t\tplainTerminal(el, url)\n\t\t\t\t\t\t\t\treturn\n\t\t\t\t\t\t\t}\n\t\t\t\t\t\t\tconst term = new Terminal({ fontSize: 13 })\n\t\t\t\t\t\t\tconst fit = new FitAddon.FitAddon()\n\t\t\t\t\t\t\tterm.loadAddon(fit)\n\t\t\t\t\t\t\tterm.open(el)\n\t\t\t\t\t\t\tfit.fit()\n\t\t\t\t\t\t\tnew ResizeObserver(() => fit.fit()).observe(el)\n\n\t\t\t\t\t\t\tconst conn = connectTerminal(url, data => term.write(data))\n\t\t\t\t\t\t\tconn.ws.onopen = () => conn.send({ type: 'resize', cols: term.cols, rows: term.rows })\n\t\t\t\t\t\t\tconn.ws.onclose = () => term.options.disableStdin = true\n\t\t\t\t\t\t\tterm.onData(data => conn.send({ type: 'input', data }))\n\t\t\t\t\t\t\tterm.onResize(({ cols, rows }) => conn.send({ type: 'resize', cols, rows }))\n\t\t\t\t\t\t\tterm.focus()\n\t\t\t\t\t\t}\n\t\t\t\t\t\tfunction connectTerminal(url, onOutput) {\n\t\t\t\t\t\t\tconst ws = new WebSocket(new URL(url, location.href).href.replace(/^http/, 'ws'))\n\t\t\t\t\t\t\tws.binaryType = 'arraybuffer'\n\t\t\t\t\t\t\tws.onmessage = ev => onOutput(new Uint8Array(ev.data))\n\t\t\t\t\t\t\treturn {\n\t\t\t\t\t\t\t\tws,\n\t\t\t\t\t\t\t\tsend(msg) {\n\t\t\t\t\t\t\t\t\tif (ws.readyState === WebSocket.OPEN) ws.send(JSON.stringify(msg))\n\t\t\t\t\t\t\t\t},\n\t\t\t\t\t\t\t}\n\t\t\t\t\t\t}\n\t\t\t\t\t\t// Without xterm.js, the output loses its colors and cursor\n\t\t\t\t\t\t// movements, but the keys still reach the script\n\t\t\t\t\t\tfunction plainTerminal(el, url) {\n\t\t\t\t\t\t\tconst out = document.createElement('pre')\n\t\t\t\t\t\t\tout.tabIndex = 0\n\t\t\t\t\t\t\tout.className = 'h-full overflow-auto whitespace-pre-wrap text-gray-200 border border-gray-700 rounded p-2 focus:outline-none focus:border-violet-400'\n\t\t\t\t\t\t\tel.append(out)\n\n\t\t\t\t\t\t\tconst decoder = new TextDecoder()\n\t\t\t\t\t\t\tconst conn = connectTerminal(url, data => {\n\t\t\t\t\t\t\t\tconst text = decoder.decode(data, { stream: true })\n\t\t\t\t\t\t\t\tout.textContent += text.replace(/\\x1b\\[[0-?]*[ -\\/]*[@-~]|\\x1b\\][^\\x07\\x1b]*(\\x07|\\x1b\\\\)?|\\x1b[ -\\/]*[0-~]|[\\x00-\\x08\\x0b-\\x1f\\x7f]/g, '')\n\t\t\t\t\t\t\t\tout.scrollTop = out.scrollHeight\n\t\t\t\t\t\t\t})\n\t\t\t\t\t\t\tconst keys = {\n\t\t\t\t\t\t\t\tEnter: '\\r', Backspace: '\\x7f', Tab: '\\t', Escape: '\\x1b',\n\t\t\t\t\t\t\t\tArrowUp: '\\x1b[A', ArrowDown: '\\x1b[B', ArrowRight: '\\x1b[C', ArrowLeft: '\\x1b[D',\n\t\t\t\t\t\t\t}\n\t\t\t\t\t\t\tout.addEventListener('keydown', ev => {\n\t\t\t\t\t\t\t\tlet data = keys[ev.key]\n\t\t\t\t\t\t\t\tif (!data && ev.key.length === 1 && ev.ctrlKey) {\n\t\t\t\t\t\t\t\t\tdata = String.fromCharCode(ev.key.toUpperCase().charCodeAt(0) & 0x1f)\n\t\t\t\t\t\t\t\t} else if (!data && ev.key.length === 1 && !ev.altKey && !ev.metaKey) {\n\t\t\t\t\t\t\t\t\tdata = ev.key\n\t\t\t\t\t\t\t\t}\n\t\t\t\t\t\t\t\tif (data) {\n\t\t\t\t\t\t\t\t\tev.preventDefault()\n\t\t\t\t\t\t\t\t\tconn.send({ type: 'input', data })\n\t\t\t\t\t\t\t\t}\n\t\t\t\t\t\t\t})\n\t\t\t\t\t\t\tout.focus()\n\t\t\t\t\t\t}\n\t\t\t\t\t</script><div class=\"bg-gray-900 text-gray-100 p-4 rounded-md font-mono text-sm overflow-auto max-h-96\"><template shadowrootmode=\"open\">")
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
-			for i, cmd := range cmds {
-				id := "cmdout-" + strconv.Itoa(i)
-				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 2, "<slot name=\"")
+			for i, act := range run.Actions {
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 2, "<div class=\"w-full mb-2\"><slot name=\"")
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
 				var templ_7745c5c3_Var3 string
-				templ_7745c5c3_Var3, templ_7745c5c3_Err = templ.JoinStringErrs(id)
+				templ_7745c5c3_Var3, templ_7745c5c3_Err = templ.JoinStringErrs(components.CommandSlot(i))
 				if templ_7745c5c3_Err != nil {
-					return templ.Error{Err: templ_7745c5c3_Err, FileName: `ui/pages/apply_changes.templ`, Line: 30, Col: 23}
+					return templ.Error{Err: templ_7745c5c3_Err, FileName: `ui/pages/apply_changes.templ`, Line: 120, Col: 47}
 				}
 				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var3))
 				if templ_7745c5c3_Err != nil {
@@ -70,15 +70,15 @@ func ApplyChanges(cmds []string) templ.Component {
 					return templ_7745c5c3_Err
 				}
 				var templ_7745c5c3_Var4 string
-				templ_7745c5c3_Var4, templ_7745c5c3_Err = templ.JoinStringErrs(cmd)
+				templ_7745c5c3_Var4, templ_7745c5c3_Err = templ.JoinStringErrs(strings.Trim(act.Script, "\n\r\t"))
 				if templ_7745c5c3_Err != nil {
-					return templ.Error{Err: templ_7745c5c3_Err, FileName: `ui/pages/apply_changes.templ`, Line: 31, Col: 45}
+					return templ.Error{Err: templ_7745c5c3_Err, FileName: `ui/pages/apply_changes.templ`, Line: 121, Col: 77}
 				}
 				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var4))
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
-				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 4, "</div><div class=\"text-gray-400\">Executing command...</div></slot>")
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 4, "</div><div class=\"text-gray-400\">Waiting for previous commands...</div></slot></div>")
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
@@ -87,35 +87,27 @@ func ApplyChanges(cmds []string) templ.Component {
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}
-			for i, cmd := range cmds {
-				id := "cmdout-" + strconv.Itoa(i)
-				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 6, "<div slot=\"")
+			for ev := range run.Events(ctx) {
+				templ_7745c5c3_Err = components.CommandEvent(run, ev).Render(ctx, templ_7745c5c3_Buffer)
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
-				var templ_7745c5c3_Var5 string
-				templ_7745c5c3_Var5, templ_7745c5c3_Err = templ.JoinStringErrs(id)
-				if templ_7745c5c3_Err != nil {
-					return templ.Error{Err: templ_7745c5c3_Err, FileName: `ui/pages/apply_changes.templ`, Line: 38, Col: 21}
-				}
-				_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var5))
-				if templ_7745c5c3_Err != nil {
-					return templ_7745c5c3_Err
-				}
-				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 7, "\">")
-				if templ_7745c5c3_Err != nil {
-					return templ_7745c5c3_Err
-				}
-				templ_7745c5c3_Err = components.Command(cmd).Render(ctx, templ_7745c5c3_Buffer)
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 6, "</div>")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			if ctx.Err() == nil {
+				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 7, "<script>document.getElementById('run-progress').remove()</script> ")
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
-				templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 8, "</div>")
+				templ_7745c5c3_Err = components.RunSummary(run.Record()).Render(ctx, templ_7745c5c3_Buffer)
 				if templ_7745c5c3_Err != nil {
 					return templ_7745c5c3_Err
 				}
 			}
-			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 9, "</div></div><div class=\"mt-6\"><div class=\"text-center\"><a href=\"/\" class=\"btn btn-primary\">Return to Home</a></div><p class=\"text-center text-sm text-gray-500 mt-2\">You can close this window when installation is complete</p></div></div></div>")
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 8, "</div><div class=\"mt-6\"><div class=\"text-center\"><a href=\"/\" class=\"btn btn-primary\">Return to Home</a></div><p class=\"text-center text-sm text-gray-500 mt-2\">You can close this window when installation is complete</p></div></div></div>")
 			if templ_7745c5c3_Err != nil {
 				return templ_7745c5c3_Err
 			}